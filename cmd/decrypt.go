@@ -1,67 +1,361 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
-	"github.com/crissyfield/decrypt/internal/decrypt"
+	"github.com/crissyfield/decrypt/pkg/decrypt"
 )
 
 // CmdDecrypt defines the 'decrypt' command.
 var CmdDecrypt = &cobra.Command{
-	Use:   "decrypt [flags] bundle_id",
-	Short: "Decrypt an iOS application",
-	Args:  cobra.ExactArgs(1),
+	Use:   "decrypt [flags] [bundle_id...]",
+	Short: "Decrypt iOS applications",
+	Args:  cobra.ArbitraryArgs,
 	Run:   runDecrypt,
 }
 
 // Initialize command options
 func init() {
+	CmdDecrypt.Flags().String("transfer", string(decrypt.TransferSFTP), "transfer strategy for the app bundle (sftp, tar, frida; tar is faster for many small files, but doesn't resume interrupted transfers)")
+	CmdDecrypt.Flags().String("work-dir", "", "directory to pull app bundles into, kept on failure to resume with --transfer sftp or frida (default: unique temporary directory, always removed)")
+	CmdDecrypt.Flags().String("output", "", "path of the resulting IPA, for a single application only")
+	CmdDecrypt.Flags().String("output-dir", ".", "directory for resulting IPAs named \"<bundle_id>_<version>_<build>.ipa\"")
+	CmdDecrypt.Flags().Bool("keep-work-dir", false, "keep the work directory after decrypting")
+	CmdDecrypt.Flags().Duration("timeout", 0, "abort decrypting after this duration (0 means no timeout)")
+	CmdDecrypt.Flags().String("from-file", "", "read bundle IDs from file (one per line)")
+	CmdDecrypt.Flags().Bool("all-user-apps", false, "decrypt all user-installed applications")
+	CmdDecrypt.Flags().String("cache-file", "", "index of produced IPAs (default: \"decrypt/index.json\" in user cache directory)")
+	CmdDecrypt.Flags().Bool("force", false, "decrypt applications even if the same version is already in the cache")
+	CmdDecrypt.Flags().Bool("no-cleanup", false, "keep all files in the app bundle (e.g. embedded.mobileprovision)")
+	CmdDecrypt.Flags().StringSlice("cleanup.files", nil, "additional file names to remove from the app bundle root")
+	CmdDecrypt.Flags().StringSlice("cleanup.dirs", nil, "additional directory names to remove from the app bundle")
+	CmdDecrypt.Flags().StringSlice("cleanup.globs", nil, "additional glob patterns of paths to remove from the app bundle")
+	CmdDecrypt.Flags().StringSlice("cleanup.keep", nil, "file names, directory names or glob patterns not to remove")
+	CmdDecrypt.Flags().Bool("signing-report", false, "write the original provisioning profiles and entitlements to \"<ipa>.signing.json\"")
+	CmdDecrypt.Flags().Bool("strip-signature", false, "remove the code signature from decrypted binaries, so they can be re-signed")
+	CmdDecrypt.Flags().Bool("adhoc-sign", false, "ad-hoc sign all decrypted and unencrypted binaries, and regenerate \"_CodeSignature/CodeResources\"")
+	CmdDecrypt.Flags().Bool("keep-entitlements", false, "embed the original entitlements when ad-hoc signing")
+	CmdDecrypt.Flags().String("report", "", "write a JSON report of all results to this file")
+	CmdDecrypt.Flags().Bool("no-scan", false, "don't scan the app bundle on the device before pulling it")
+	CmdDecrypt.Flags().Duration("daemon-timeout", 30*time.Second, "time to wait for chronod and runningboardd to start")
 }
 
 // runDecrypt is called when the 'decrypt' sub-command is used.
-func runDecrypt(_ *cobra.Command, args []string) {
+func runDecrypt(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Collect requested bundle IDs
+	bundleIDs := args
+
+	if path := viper.GetString("from-file"); path != "" {
+		ids, err := readBundleIDs(path)
+		if err != nil {
+			slog.Error("Failed to read bundle IDs", slog.String("path", path), slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		bundleIDs = append(bundleIDs, ids...)
+	}
+
+	if len(bundleIDs) == 0 && !viper.GetBool("all-user-apps") {
+		slog.Error("No application specified (use bundle IDs, --from-file or --all-user-apps)")
+		os.Exit(1)
+	}
+
+	// Open cache
+	cachePath := viper.GetString("cache-file")
+
+	if cachePath == "" {
+		path, err := decrypt.DefaultCachePath()
+		if err != nil {
+			slog.Error("Failed to determine cache path", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		cachePath = path
+	}
+
+	cache, err := decrypt.OpenCache(cachePath)
+	if err != nil {
+		slog.Error("Failed to open cache", slog.String("path", cachePath), slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Build cleanup rules
+	cleanup := decrypt.DefaultCleanupRules().
+		Add(decrypt.CleanupRules{
+			Files: viper.GetStringSlice("cleanup.files"),
+			Dirs:  viper.GetStringSlice("cleanup.dirs"),
+			Globs: viper.GetStringSlice("cleanup.globs"),
+		}).
+		Remove(viper.GetStringSlice("cleanup.keep")...)
+
+	err = cleanup.Validate()
+	if err != nil {
+		slog.Error("Invalid cleanup rules", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Build dump options
+	opts := decrypt.DumpOptions{
+		Transfer:       decrypt.Transfer(viper.GetString("transfer")),
+		WorkDir:        viper.GetString("work-dir"),
+		Output:         viper.GetString("output"),
+		OutputDir:      viper.GetString("output-dir"),
+		KeepWorkDir:    viper.GetBool("keep-work-dir"),
+		Progress:       newProgress(),
+		Cache:          cache,
+		Force:          viper.GetBool("force"),
+		DaemonTimeout:  viper.GetDuration("daemon-timeout"),
+		Cleanup:        &cleanup,
+		NoCleanup:      viper.GetBool("no-cleanup"),
+		SigningReport:  viper.GetBool("signing-report"),
+		StripSignature: viper.GetBool("strip-signature"),
+		AdhocSign:      viper.GetBool("adhoc-sign"),
+		Sign:           decrypt.SignOptions{KeepEntitlements: viper.GetBool("keep-entitlements")},
+		NoScan:         viper.GetBool("no-scan"),
+		SSH:            sshOptions(),
+	}
+
+	err = opts.Validate()
+	if err != nil {
+		slog.Error("Invalid options", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	// Find the specified device
-	device, err := decrypt.FindDevice()
+	stopSpinner := startSpinner("Looking for device")
+
+	device, err := decrypt.FindDevice(ctx)
+	stopSpinner()
+
 	if err != nil {
 		slog.Error("Failed to find device", slog.Any("error", err))
 		os.Exit(1)
 	}
 
 	// Ensure the device meets the requirements
-	if device.Access != "full" || device.Platform != "darwin" || device.OS != "ios" || device.Arch != "arm64" {
-		slog.Error("Jailbroken 64-bit iOS device required")
+	err = device.CheckRequirements()
+	if err != nil {
+		slog.Error("Jailbroken 64-bit iOS device required", slog.Any("error", err))
 		os.Exit(1)
 	}
 
 	// List applications
-	apps, err := device.ListApplications()
+	stopSpinner = startSpinner("Listing applications")
+
+	apps, err := device.ListApplications(ctx)
+	stopSpinner()
+
 	if err != nil {
 		slog.Error("Failed to list applications", slog.Any("error", err))
 		os.Exit(1)
 	}
 
-	// Find the application with the specified identifier
-	var application *decrypt.Application
+	// Select the applications to decrypt
+	var results []decryptResult
+
+	appsByID := make(map[string]*decrypt.Application)
 
 	for _, app := range apps {
-		if app.Identifier == args[0] {
-			application = app
-			break
+		appsByID[app.Identifier] = app
+	}
+
+	var selected []*decrypt.Application
+
+	if viper.GetBool("all-user-apps") {
+		for _, app := range apps {
+			if app.IsUserApp() {
+				selected = append(selected, app)
+			}
+		}
+	}
+
+	for _, id := range bundleIDs {
+		app, ok := appsByID[id]
+		if !ok {
+			slog.Error("Application not found", slog.String("identifier", id))
+			results = append(results, decryptResult{identifier: id, err: errors.New("application not found")})
+
+			continue
+		}
+
+		if !slices.Contains(selected, app) {
+			selected = append(selected, app)
 		}
 	}
 
-	if application == nil {
-		slog.Error("Application not found", "identifier", "de.deutschepost.dhl")
+	if (viper.GetString("output") != "") && (len(selected)+len(results) > 1) {
+		slog.Error("The --output option requires a single application (use --output-dir instead)")
 		os.Exit(1)
 	}
 
-	// Dump the application
-	err = application.Dump()
-	if err != nil {
-		slog.Error("Failed to dump application", slog.Any("error", err))
+	// Dump the applications, sharing one dumper
+	dumper := device.NewDumper(opts)
+
+	for _, app := range selected {
+		// Stop on cancellation
+		if ctx.Err() != nil {
+			results = append(results, decryptResult{identifier: app.Identifier, version: app.Version, err: ctx.Err()})
+			continue
+		}
+
+		slog.Info("Decrypting application", slog.String("identifier", app.Identifier), slog.String("version", app.Version))
+
+		dump, err := dumper.Dump(ctx, app)
+		if errors.Is(err, decrypt.ErrCached) {
+			slog.Info("Skipping application", slog.String("identifier", app.Identifier), slog.Any("reason", err))
+		} else if err != nil {
+			slog.Error("Failed to dump application", slog.String("identifier", app.Identifier), slog.Any("error", err))
+		}
+
+		results = append(results, decryptResult{identifier: app.Identifier, version: app.Version, dump: dump, err: err})
+	}
+
+	dumper.Close()
+
+	// Write report
+	if path := viper.GetString("report"); path != "" {
+		err := writeDecryptReport(path, results)
+		if err != nil {
+			slog.Error("Failed to write report", slog.String("path", path), slog.Any("error", err))
+		}
+	}
+
+	// Render summary, and fail if any application failed
+	failed := renderDecryptResults(results)
+	if failed {
 		os.Exit(1)
 	}
 }
+
+// decryptResult is the result of decrypting a single application.
+type decryptResult struct {
+	identifier string              // identifier of the application.
+	version    string              // version of the application.
+	dump       *decrypt.DumpResult // dump is the result of dumping the application (nil if not dumped).
+	err        error               // err is the error that occurred, if any.
+}
+
+// renderDecryptResults renders a summary table of the results, and returns true if any application failed.
+func renderDecryptResults(results []decryptResult) bool {
+	failed := false
+	tableData := pterm.TableData{{"Bundle ID", "Version", "Binaries", "Time", "Result"}}
+
+	for _, res := range results {
+		result, binaries, duration := "OK", "", ""
+
+		if res.dump != nil && len(res.dump.Binaries) > 0 {
+			decrypted := 0
+
+			for _, binary := range res.dump.Binaries {
+				if binary.Decrypted() {
+					decrypted++
+				}
+			}
+
+			binaries = fmt.Sprintf("%d/%d decrypted", decrypted, len(res.dump.Binaries))
+		}
+
+		if res.dump != nil && !errors.Is(res.err, decrypt.ErrCached) {
+			duration = res.dump.Timings.Total.Round(time.Second).String()
+		}
+
+		if errors.Is(res.err, decrypt.ErrCached) {
+			result = "Skipped: " + res.err.Error()
+		} else if res.err != nil {
+			result = "Failed: " + res.err.Error()
+			failed = true
+		}
+
+		tableData = append(tableData, []string{res.identifier, res.version, binaries, duration, result})
+	}
+
+	err := pterm.DefaultTable.
+		WithHasHeader().
+		WithHeaderRowSeparator("-").
+		WithData(tableData).
+		Render()
+
+	if err != nil {
+		slog.Error("Failed to render summary", slog.Any("error", err))
+	}
+
+	return failed
+}
+
+// decryptReportEntry is the JSON representation of a result in the report.
+type decryptReportEntry struct {
+	Identifier string              `json:"identifier"`
+	Version    string              `json:"version,omitempty"`
+	Status     string              `json:"status"`
+	Error      string              `json:"error,omitempty"`
+	Dump       *decrypt.DumpResult `json:"dump,omitempty"`
+}
+
+// writeDecryptReport writes the results as JSON to a file.
+func writeDecryptReport(path string, results []decryptResult) error {
+	entries := make([]decryptReportEntry, 0, len(results))
+
+	for _, res := range results {
+		entry := decryptReportEntry{Identifier: res.identifier, Version: res.version, Status: "ok", Dump: res.dump}
+
+		if errors.Is(res.err, decrypt.ErrCached) {
+			entry.Status = "skipped"
+		} else if res.err != nil {
+			entry.Status = "failed"
+		}
+
+		if res.err != nil {
+			entry.Error = res.err.Error()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// readBundleIDs reads bundle IDs from a file, one per line. Empty lines and lines starting with '#' are ignored.
+func readBundleIDs(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids = append(ids, line)
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/crissyfield/decrypt/pkg/decrypt"
+)
+
+// CmdDoctor defines the 'doctor' command.
+var CmdDoctor = &cobra.Command{
+	Use:   "doctor [flags]",
+	Short: "Check the prerequisites for decrypting apps",
+	Args:  cobra.NoArgs,
+	Run:   runDoctor,
+}
+
+// Initialize command options
+func init() {
+	CmdDoctor.Flags().String("output-dir", ".", "directory whose free disk space is checked")
+}
+
+// runDoctor is called when the 'doctor' sub-command is used.
+func runDoctor(cmd *cobra.Command, _ []string) {
+	// Check prerequisites
+	stopSpinner := startSpinner("Checking prerequisites")
+
+	checks := decrypt.Diagnose(cmd.Context(), decrypt.DoctorOptions{
+		OutputDir: viper.GetString("output-dir"),
+		SSH:       sshOptions(),
+	})
+
+	stopSpinner()
+
+	// Render check results
+	failed := false
+	tableData := pterm.TableData{{"Check", "Result", "Details", "Hint"}}
+
+	for _, check := range checks {
+		result, hint := "Pass", ""
+
+		if !check.Passed() {
+			result, hint = "Fail: "+check.Err.Error(), check.Hint
+			failed = true
+		}
+
+		tableData = append(tableData, []string{check.Name, result, check.Detail, hint})
+	}
+
+	err := pterm.DefaultTable.
+		WithHasHeader().
+		WithHeaderRowSeparator("-").
+		WithData(tableData).
+		Render()
+
+	if err != nil {
+		slog.Error("Failed render check results", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
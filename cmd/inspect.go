@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/crissyfield/decrypt/pkg/decrypt"
+)
+
+// CmdInspect defines the 'inspect' command.
+var CmdInspect = &cobra.Command{
+	Use:   "inspect [flags] <ipa>",
+	Short: "List the binaries of an IPA file",
+	Args:  cobra.ExactArgs(1),
+	Run:   runInspect,
+}
+
+// Initialize command options
+func init() {
+	CmdInspect.Flags().Bool("entitlements", false, "print the entitlements of every signed binary")
+}
+
+// runInspect is called when the 'inspect' sub-command is used.
+func runInspect(_ *cobra.Command, args []string) {
+	// Open IPA file
+	ipa, err := decrypt.OpenIPA(args[0])
+	if err != nil {
+		slog.Error("Failed to open IPA file", slog.String("path", args[0]), slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	defer ipa.Close()
+
+	// Scan binaries
+	binaries, err := decrypt.ScanBinaries(ipa.Bundle())
+	if err != nil {
+		slog.Error("Failed to scan binaries", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Render binary list
+	pterm.Println("App bundle: " + ipa.BundleName)
+
+	tableData := pterm.TableData{{"Path", "Type", "Slice", "Min OS", "Encrypted", "Encrypted Range"}}
+
+	for _, info := range binaries {
+		encrypted, cryptRange := "no", ""
+
+		if info.CryptID != 0 {
+			encrypted = fmt.Sprintf("yes (cryptid %d)", info.CryptID)
+		}
+
+		if info.CryptSize != 0 {
+			cryptRange = fmt.Sprintf("0x%x-0x%x", info.CryptOffset, info.CryptOffset+info.CryptSize)
+		}
+
+		tableData = append(tableData, []string{info.Path, info.FileTypeName(), info.SliceName(), info.MinOS, encrypted, cryptRange})
+	}
+
+	err = pterm.DefaultTable.
+		WithHasHeader().
+		WithHeaderRowSeparator("-").
+		WithData(tableData).
+		Render()
+
+	if err != nil {
+		slog.Error("Failed render binary list", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Render code signatures
+	tableData = pterm.TableData{{"Path", "Identifier", "Team ID", "Hash", "CDHash", "Requirements", "Signature"}}
+
+	for _, info := range binaries {
+		cs := info.Signature
+		if cs == nil {
+			tableData = append(tableData, []string{info.Path, "", "", "", "", "", "none"})
+			continue
+		}
+
+		cd := cs.CodeDirectory()
+
+		var reqs []string
+
+		for _, req := range cs.Requirements {
+			reqs = append(reqs, req.Type)
+		}
+
+		signature := "ad-hoc"
+		if cs.Signed {
+			signature = "CMS"
+		}
+
+		tableData = append(tableData, []string{info.Path, cd.Identifier, cd.TeamID, cd.HashType, cd.CDHash, strings.Join(reqs, ", "), signature})
+	}
+
+	pterm.Println()
+
+	err = pterm.DefaultTable.
+		WithHasHeader().
+		WithHeaderRowSeparator("-").
+		WithData(tableData).
+		Render()
+
+	if err != nil {
+		slog.Error("Failed render code signatures", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Print entitlements
+	if !viper.GetBool("entitlements") {
+		return
+	}
+
+	for _, info := range binaries {
+		if info.Signature == nil || info.Signature.EffectiveEntitlements() == nil {
+			continue
+		}
+
+		content, err := json.MarshalIndent(info.Signature.EffectiveEntitlements(), "", "  ")
+		if err != nil {
+			slog.Error("Failed to encode entitlements", slog.String("path", info.Path), slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		pterm.Printf("\nEntitlements of %s:\n%s\n", info.Path, content)
+	}
+}
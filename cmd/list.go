@@ -7,7 +7,7 @@ import (
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 
-	"github.com/crissyfield/decrypt/internal/decrypt"
+	"github.com/crissyfield/decrypt/pkg/decrypt"
 )
 
 // CmdList defines the 'list' command.
@@ -23,22 +23,33 @@ func init() {
 }
 
 // runList is called when the 'list' sub-command is used.
-func runList(_ *cobra.Command, _ []string) {
+func runList(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
+
 	// Find the specified device
-	device, err := decrypt.FindDevice()
+	stopSpinner := startSpinner("Looking for device")
+
+	device, err := decrypt.FindDevice(ctx)
+	stopSpinner()
+
 	if err != nil {
 		slog.Error("Failed to find device", slog.Any("error", err))
 		os.Exit(1)
 	}
 
 	// Ensure the device meets the requirements
-	if device.Access != "full" || device.Platform != "darwin" || device.OS != "ios" || device.Arch != "arm64" {
-		slog.Error("Jailbroken 64-bit iOS device required")
+	err = device.CheckRequirements()
+	if err != nil {
+		slog.Error("Jailbroken 64-bit iOS device required", slog.Any("error", err))
 		os.Exit(1)
 	}
 
 	// List applications
-	apps, err := device.ListApplications()
+	stopSpinner = startSpinner("Listing applications")
+
+	apps, err := device.ListApplications(ctx)
+	stopSpinner()
+
 	if err != nil {
 		slog.Error("Failed to list applications", slog.Any("error", err))
 		os.Exit(1)
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+
+	"github.com/crissyfield/decrypt/pkg/decrypt"
+)
+
+// progressEnabled returns true if progress should be rendered, which is only the case if stderr is a terminal and
+// logging isn't set to JSON.
+func progressEnabled() bool {
+	return !viper.GetBool("logging.json") && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// startSpinner shows a spinner with the given text (if progress is enabled) until the returned function is called.
+func startSpinner(text string) func() {
+	if !progressEnabled() {
+		return func() {}
+	}
+
+	spinner, err := pterm.DefaultSpinner.WithWriter(os.Stderr).WithRemoveWhenDone().Start(text)
+	if err != nil {
+		return func() {}
+	}
+
+	return func() { spinner.Stop() } //nolint:errcheck
+}
+
+// newProgress returns a progress that renders dump steps on stderr, or nil if progress is disabled.
+func newProgress() decrypt.Progress {
+	if !progressEnabled() {
+		return nil
+	}
+
+	return &ptermProgress{}
+}
+
+// ptermProgress renders dump steps using a pterm progress bar for steps with a known total, and a pterm spinner for
+// all other steps.
+type ptermProgress struct {
+	bar     *pterm.ProgressbarPrinter // bar of the current step, if the total is known.
+	spinner *pterm.SpinnerPrinter     // spinner of the current step, if the total is unknown.
+}
+
+// Start starts a new step.
+func (p *ptermProgress) Start(title string, total int64) {
+	p.Stop()
+
+	if total > 0 {
+		p.bar, _ = pterm.DefaultProgressbar.
+			WithWriter(os.Stderr).
+			WithTotal(int(total)).
+			WithShowCount(false).
+			WithRemoveWhenDone().
+			Start(title)
+	} else {
+		p.spinner, _ = pterm.DefaultSpinner.
+			WithWriter(os.Stderr).
+			WithRemoveWhenDone().
+			Start(title)
+	}
+}
+
+// Add reports n units of completed work for the current step.
+func (p *ptermProgress) Add(n int64) {
+	if p.bar != nil && p.bar.IsActive {
+		p.bar.Add(int(n))
+	}
+}
+
+// UpdateTitle changes the title of the current step.
+func (p *ptermProgress) UpdateTitle(title string) {
+	if p.bar != nil && p.bar.IsActive {
+		p.bar.UpdateTitle(title)
+	}
+
+	if p.spinner != nil {
+		p.spinner.UpdateText(title)
+	}
+}
+
+// Stop stops the current step.
+func (p *ptermProgress) Stop() {
+	if p.bar != nil {
+		p.bar.Stop() //nolint:errcheck
+		p.bar = nil
+	}
+
+	if p.spinner != nil {
+		p.spinner.Stop() //nolint:errcheck
+		p.spinner = nil
+	}
+}
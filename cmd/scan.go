@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/crissyfield/decrypt/pkg/decrypt"
+)
+
+// CmdScan defines the 'scan' command.
+var CmdScan = &cobra.Command{
+	Use:   "scan [flags] <bundle_id>",
+	Short: "Scan the binaries of an app on the device without pulling it",
+	Args:  cobra.ExactArgs(1),
+	Run:   runScan,
+}
+
+// Initialize command options
+func init() {
+}
+
+// runScan is called when the 'scan' sub-command is used.
+func runScan(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+
+	// Find the specified device
+	stopSpinner := startSpinner("Looking for device")
+
+	device, err := decrypt.FindDevice(ctx)
+	stopSpinner()
+
+	if err != nil {
+		slog.Error("Failed to find device", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Ensure the device meets the requirements
+	err = device.CheckRequirements()
+	if err != nil {
+		slog.Error("Jailbroken 64-bit iOS device required", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Find application
+	stopSpinner = startSpinner("Listing applications")
+
+	apps, err := device.ListApplications(ctx)
+	stopSpinner()
+
+	if err != nil {
+		slog.Error("Failed to list applications", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	var app *decrypt.Application
+
+	for _, a := range apps {
+		if a.Identifier == args[0] {
+			app = a
+			break
+		}
+	}
+
+	if app == nil {
+		slog.Error("Application not found", slog.String("identifier", args[0]))
+		os.Exit(1)
+	}
+
+	// Scan app bundle
+	dumper := device.NewDumper(decrypt.DumpOptions{Progress: newProgress(), SSH: sshOptions()})
+	defer dumper.Close()
+
+	scan, err := dumper.Scan(ctx, app)
+	if err != nil {
+		slog.Error("Failed to scan app bundle", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Render binary list
+	pterm.Printf("App bundle: %s (%d files, %d bytes)\n", app.Path, scan.Files, scan.Size)
+
+	unsupported := make(map[*decrypt.MachOInfo]bool)
+
+	for _, info := range scan.Unsupported(device.OSVersion) {
+		unsupported[info] = true
+	}
+
+	tableData := pterm.TableData{{"Path", "Type", "Encrypted", "Encrypted Size", "Min OS", "Note"}}
+
+	for _, info := range scan.Binaries {
+		encrypted, note := "no", ""
+
+		if info.CryptID != 0 {
+			encrypted = "yes"
+		}
+
+		if unsupported[info] {
+			note = fmt.Sprintf("requires newer OS than %s, will be left encrypted", device.OSVersion)
+		}
+
+		tableData = append(tableData, []string{info.Path, info.FileTypeName(), encrypted, fmt.Sprint(info.CryptSize), info.MinOS, note})
+	}
+
+	err = pterm.DefaultTable.
+		WithHasHeader().
+		WithHeaderRowSeparator("-").
+		WithData(tableData).
+		Render()
+
+	if err != nil {
+		slog.Error("Failed render binary list", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
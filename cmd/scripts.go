@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/crissyfield/decrypt/pkg/decrypt"
+)
+
+// CmdScripts defines the 'scripts' command.
+var CmdScripts = &cobra.Command{
+	Use:   "scripts [flags]",
+	Short: "List the Frida scripts and their versions",
+	Args:  cobra.NoArgs,
+	Run:   runScripts,
+}
+
+// Initialize command options
+func init() {
+}
+
+// runScripts is called when the 'scripts' sub-command is used.
+func runScripts(_ *cobra.Command, _ []string) {
+	// List scripts
+	scripts, err := decrypt.ScriptVersions()
+	if err != nil {
+		slog.Error("Failed to list scripts", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Render script list
+	tableData := pterm.TableData{{"Name", "Version", "SHA-256", "Source"}}
+
+	for _, script := range scripts {
+		tableData = append(tableData, []string{script.Name, script.Version, script.Hash[:12], script.Source})
+	}
+
+	err = pterm.DefaultTable.
+		WithHasHeader().
+		WithHeaderRowSeparator("-").
+		WithData(tableData).
+		Render()
+
+	if err != nil {
+		slog.Error("Failed render script list", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/viper"
+
+	"github.com/crissyfield/decrypt/pkg/decrypt"
+)
+
+// sshOptions returns the SSH options set via the global "ssh.*" flags.
+func sshOptions() decrypt.SSHOptions {
+	return decrypt.SSHOptions{
+		User:     viper.GetString("ssh.user"),
+		Password: viper.GetString("ssh.password"),
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/crissyfield/decrypt/cmd"
+	"github.com/crissyfield/decrypt/pkg/decrypt"
 )
 
 var (
@@ -32,9 +36,20 @@ func init() {
 	CmdRoot.PersistentFlags().String("logging.level", "info", "verbosity of logging output")
 	CmdRoot.PersistentFlags().Bool("logging.json", false, "change logging format to JSON")
 
+	// SSH
+	CmdRoot.PersistentFlags().String("ssh.user", "mobile", "user to log in as over SSH (launchctl is run via sudo unless \"root\")")
+	CmdRoot.PersistentFlags().String("ssh.password", "alpine", "password of the SSH user, also passed to sudo")
+
+	// Scripts
+	CmdRoot.PersistentFlags().String("scripts-dir", "", "read Frida scripts from this directory instead of the embedded ones")
+
 	// Register sub-commands
 	CmdRoot.AddCommand(cmd.CmdDecrypt)
 	CmdRoot.AddCommand(cmd.CmdList)
+	CmdRoot.AddCommand(cmd.CmdInspect)
+	CmdRoot.AddCommand(cmd.CmdScan)
+	CmdRoot.AddCommand(cmd.CmdDoctor)
+	CmdRoot.AddCommand(cmd.CmdScripts)
 }
 
 // setup will set up configuration management and logging.
@@ -82,12 +97,24 @@ func setup(cmd *cobra.Command, _ []string) error {
 
 	slog.SetDefault(slog.New(handler))
 
+	// Scripts
+	err = decrypt.UseScriptsDir(viper.GetString("scripts-dir"))
+	if err != nil {
+		return fmt.Errorf("validate scripts: %w", err)
+	}
+
 	return nil
 }
 
 // main is the main entry point of the command.
 func main() {
-	if err := CmdRoot.Execute(); err != nil {
+	// Cancel on SIGINT or SIGTERM (a second signal terminates immediately)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	context.AfterFunc(ctx, stop)
+
+	if err := CmdRoot.ExecuteContext(ctx); err != nil {
 		slog.Error("Unable to execute command", slog.Any("error", err))
 	}
 }
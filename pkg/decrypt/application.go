@@ -1,7 +1,9 @@
 package decrypt
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/frida/frida-go/frida"
 	"github.com/go-viper/mapstructure/v2"
@@ -18,9 +20,12 @@ type Application struct {
 }
 
 // ListApplications retrieves all applications installed on the device.
-func (dev *Device) ListApplications() ([]*Application, error) {
+func (dev *Device) ListApplications(ctx context.Context) ([]*Application, error) {
 	// Enumerate applications
-	apps, err := dev.device.EnumerateApplications("", frida.ScopeFull)
+	cancel, release := withCancellable(ctx)
+	defer release()
+
+	apps, err := dev.device.EnumerateApplications("", frida.ScopeFull, cancel)
 	if err != nil {
 		return nil, fmt.Errorf("enumerate applications: %w", err)
 	}
@@ -58,3 +63,8 @@ func (dev *Device) ListApplications() ([]*Application, error) {
 
 	return applications, nil
 }
+
+// IsUserApp returns true if the application has been installed by the user (as opposed to a system application).
+func (app *Application) IsUserApp() bool {
+	return strings.Contains(app.Path, "/containers/Bundle/Application/")
+}
@@ -0,0 +1,148 @@
+package decrypt
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"strings"
+	"unsafe"
+)
+
+// Extension represents a collection of extensions associated with an application.
+type Extension struct {
+	ID           string `mapstructure:"id"`           // ID of the extension
+	Path         string `mapstructure:"path"`         // Path to the extension, relative to the app bundle
+	Executable   string `mapstructure:"executable"`   // Executable name of the extension
+	AbsolutePath string `mapstructure:"absolutePath"` // Absolute path to the extension's executable
+}
+
+// ScanBinaries returns all thin 64-bit Mach-O binaries in the app bundle fsys, encrypted or not, including their
+// parsed code signatures. Paths are relative to the root of fsys. Files that can't be parsed are skipped with a
+// warning.
+func ScanBinaries(fsys fs.FS) ([]*MachOInfo, error) {
+	scan, err := scanBundle(fsys, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return scan.Binaries, nil
+}
+
+// scanBundle walks the app bundle fsys, counting files and bytes, and parsing the header and load commands of every
+// Mach-O binary. Code signatures are parsed too if withSignatures is set.
+func scanBundle(fsys fs.FS, withSignatures bool) (*ScanResult, error) {
+	scan := &ScanResult{}
+
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories and special files
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("get file info [%s]: %w", path, err)
+		}
+
+		scan.Files++
+		scan.Size += info.Size()
+
+		// Skip files too small for a Mach-O header
+		if info.Size() < int64(unsafe.Sizeof(machOHeader{})) {
+			return nil
+		}
+
+		// Parse Mach-O binary
+		binary, err := parseMachOFile(fsys, path)
+		if err != nil {
+			slog.Warn("Failed to parse Mach-O binary", slog.String("path", path), slog.Any("error", err))
+			return nil
+		}
+
+		if binary == nil {
+			return nil
+		}
+
+		if withSignatures {
+			binary.Signature, err = ReadCodeSignature(fsys, binary)
+			if err != nil {
+				slog.Warn("Failed to parse code signature", slog.String("path", path), slog.Any("error", err))
+			}
+		}
+
+		scan.Binaries = append(scan.Binaries, binary)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("walk directory: %w", err)
+	}
+
+	return scan, nil
+}
+
+// collectBinaries collects encrypted Mach-O binaries in the app bundle fsys.
+func collectBinaries(fsys fs.FS) ([]*MachOInfo, error) {
+	scan, err := scanBundle(fsys, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return scan.Encrypted(), nil
+}
+
+// parseMachOFile parses a Mach-O binary in fsys.
+func parseMachOFile(fsys fs.FS, path string) (*MachOInfo, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+
+	defer file.Close()
+
+	return parseMachO(bufio.NewReader(file), path)
+}
+
+// splitBinaries splits binaries into app and extension binaries.
+func splitBinaries(binaries []*MachOInfo, main string, extensions []Extension) (map[string]*MachOInfo, map[string]map[string]*MachOInfo) {
+	// Iterate over binaries
+	appBinaries := make(map[string]*MachOInfo)
+	extensionBinaries := make(map[string]map[string]*MachOInfo)
+
+	for _, binary := range binaries {
+		// Check if binary belongs to an extension
+		foundExtension := false
+		for _, ext := range extensions {
+			if strings.HasPrefix(binary.Path, ext.Path+"/") {
+				if extensionBinaries[ext.ID] == nil {
+					extensionBinaries[ext.ID] = make(map[string]*MachOInfo)
+				}
+
+				extensionBinaries[ext.ID][binary.Path] = binary
+				foundExtension = true
+				break
+			}
+		}
+
+		if foundExtension {
+			continue
+		}
+
+		// Check if binary is the main app binary
+		if (binary.FileType == MH_EXECUTE) && (binary.Path != main) {
+			slog.Info("Executable is not within an extension", slog.String("path", binary.Path))
+			slog.Info("It is very likely that one of the extensions requires a MinimumOSVersion")
+			slog.Info("that is higher than your OS. This will result in a binary that is left encrypted.")
+		}
+
+		appBinaries[binary.Path] = binary
+	}
+
+	return appBinaries, extensionBinaries
+}
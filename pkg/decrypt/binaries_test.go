@@ -0,0 +1,103 @@
+package decrypt
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCollectBinaries(t *testing.T) {
+	encrypted := testMachO(MH_EXECUTE, testEncryptionInfo(0x4000, 0x4000, 1))
+	decrypted := testMachO(MH_DYLIB, testEncryptionInfo(0x4000, 0x4000, 0))
+	unencrypted := testMachO(MH_DYLIB)
+
+	tests := []struct {
+		name string       // name is the name of the test.
+		fsys fstest.MapFS // fsys is the app bundle.
+		want []string     // want are the paths of the expected binaries.
+	}{
+		{
+			name: "encrypted binaries",
+			fsys: fstest.MapFS{
+				"Ex":                       {Data: encrypted},
+				"Frameworks/A.framework/A": {Data: encrypted},
+				"PlugIns/W.appex/W":        {Data: encrypted},
+			},
+			want: []string{"Ex", "Frameworks/A.framework/A", "PlugIns/W.appex/W"},
+		},
+		{
+			name: "unencrypted binaries",
+			fsys: fstest.MapFS{
+				"Ex":                 {Data: encrypted},
+				"Frameworks/B.dylib": {Data: unencrypted},
+				"Frameworks/C.dylib": {Data: decrypted},
+			},
+			want: []string{"Ex"},
+		},
+		{
+			name: "other files",
+			fsys: fstest.MapFS{
+				"Ex":         {Data: encrypted},
+				"Info.plist": {Data: []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<plist version=\"1.0\"><dict/></plist>\n")},
+				"PkgInfo":    {Data: []byte("APPL????")},
+				"Truncated":  {Data: encrypted[:40]},
+				"Empty":      {Data: nil},
+			},
+			want: []string{"Ex"},
+		},
+		{
+			name: "no binaries",
+			fsys: fstest.MapFS{
+				"Info.plist": {Data: []byte("info")},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binaries, err := collectBinaries(tt.fsys)
+			if err != nil {
+				t.Fatalf("collectBinaries() = %v", err)
+			}
+
+			var got []string
+			for _, binary := range binaries {
+				got = append(got, binary.Path)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("collectBinaries() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectBinariesMasked(t *testing.T) {
+	encrypted := testMachO(MH_EXECUTE, testEncryptionInfo(0x4000, 0x4000, 1))
+
+	fsys := MaskFS(fstest.MapFS{
+		"Ex":                       {Data: encrypted},
+		"Frameworks/A.framework/A": {Data: encrypted},
+		"PlugIns/W.appex/W":        {Data: encrypted},
+	})
+
+	err := fsys.RemoveAll("PlugIns")
+	if err != nil {
+		t.Fatalf("RemoveAll() = %v", err)
+	}
+
+	binaries, err := collectBinaries(fsys)
+	if err != nil {
+		t.Fatalf("collectBinaries() = %v", err)
+	}
+
+	var got []string
+	for _, binary := range binaries {
+		got = append(got, binary.Path)
+	}
+
+	if want := []string{"Ex", "Frameworks/A.framework/A"}; !slices.Equal(got, want) {
+		t.Errorf("collectBinaries() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package decrypt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCached is returned when dumping an application is skipped, because the same version and build has already been
+// dumped before.
+var ErrCached = errors.New("already dumped")
+
+// CacheEntry represents an IPA produced for a specific version and build of an application.
+type CacheEntry struct {
+	Identifier string    `json:"identifier"` // Identifier is the unique identifier of the application.
+	Version    string    `json:"version"`    // Version is the version of the application.
+	Build      string    `json:"build"`      // Build is the build number of the application.
+	Path       string    `json:"path"`       // Path is the absolute path of the IPA.
+	Created    time.Time `json:"created"`    // Created is the time the IPA was produced.
+}
+
+// Cache is an index of produced IPAs, persisted as a JSON file.
+type Cache struct {
+	path    string       // path of the index file.
+	mu      sync.Mutex   // mu guards entries.
+	entries []CacheEntry // entries of the index.
+}
+
+// DefaultCachePath returns the default path of the cache index in the user's cache directory.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("get user cache directory: %w", err)
+	}
+
+	return filepath.Join(dir, "decrypt", "index.json"), nil
+}
+
+// OpenCache opens the cache index at path. A missing index is treated as empty.
+func OpenCache(path string) (*Cache, error) {
+	cache := &Cache{path: path}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read cache index: %w", err)
+	}
+
+	err = json.Unmarshal(content, &cache.entries)
+	if err != nil {
+		return nil, fmt.Errorf("decode cache index: %w", err)
+	}
+
+	return cache, nil
+}
+
+// Lookup returns the entry for the application's version and build. Entries whose IPA no longer exists are ignored.
+func (c *Cache) Lookup(app *Application) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range c.entries {
+		if entry.Identifier != app.Identifier || entry.Version != app.Version || entry.Build != app.Build {
+			continue
+		}
+
+		if _, err := os.Stat(entry.Path); err == nil {
+			return entry, true
+		}
+	}
+
+	return CacheEntry{}, false
+}
+
+// Add records the IPA produced for the application's version and build, and persists the index.
+func (c *Cache) Add(app *Application, path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("get absolute path: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Replace existing entry for the same version and build
+	entry := CacheEntry{
+		Identifier: app.Identifier,
+		Version:    app.Version,
+		Build:      app.Build,
+		Path:       path,
+		Created:    time.Now(),
+	}
+
+	entries := []CacheEntry{entry}
+
+	for _, e := range c.entries {
+		if e.Identifier != app.Identifier || e.Version != app.Version || e.Build != app.Build {
+			entries = append(entries, e)
+		}
+	}
+
+	c.entries = entries
+
+	return c.save()
+}
+
+// save writes the index atomically. The index is left untouched, if writing fails.
+func (c *Cache) save() error {
+	content, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode cache index: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.path), 0755)
+	if err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+
+	err = os.WriteFile(tmp, content, 0644)
+	if err != nil {
+		os.Remove(tmp) //nolint:errcheck
+		return fmt.Errorf("write cache index: %w", err)
+	}
+
+	err = os.Rename(tmp, c.path)
+	if err != nil {
+		os.Remove(tmp) //nolint:errcheck
+		return fmt.Errorf("replace cache index: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package decrypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCache opens a cache in a temporary directory, with an IPA recorded for version 1.0 build 100 of app.
+func testCache(t *testing.T, app *Application) (*Cache, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	cache, err := OpenCache(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatalf("OpenCache() = %v", err)
+	}
+
+	ipa := filepath.Join(dir, "app.ipa")
+
+	err = os.WriteFile(ipa, []byte("ipa"), 0644)
+	if err != nil {
+		t.Fatalf("write IPA: %v", err)
+	}
+
+	err = cache.Add(app, ipa)
+	if err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+
+	return cache, ipa
+}
+
+func TestCacheLookup(t *testing.T) {
+	app := &Application{Identifier: "com.example.app", Version: "1.0", Build: "100"}
+
+	tests := []struct {
+		name      string       // name is the name of the test.
+		app       *Application // app is the looked up application.
+		deleteIPA bool         // deleteIPA is true if the IPA is deleted before the lookup.
+		force     bool         // force is true if dumping is forced.
+		want      bool         // want is true if the lookup is expected to hit.
+	}{
+		{name: "hit", app: app, want: true},
+		{name: "other identifier", app: &Application{Identifier: "com.example.other", Version: "1.0", Build: "100"}, want: false},
+		{name: "other version", app: &Application{Identifier: "com.example.app", Version: "1.1", Build: "100"}, want: false},
+		{name: "other build", app: &Application{Identifier: "com.example.app", Version: "1.0", Build: "101"}, want: false},
+		{name: "deleted IPA", app: app, deleteIPA: true, want: false},
+		{name: "forced", app: app, force: true, want: false},
+		{name: "forced other version", app: &Application{Identifier: "com.example.app", Version: "1.1", Build: "100"}, force: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, ipa := testCache(t, app)
+
+			if tt.deleteIPA {
+				err := os.Remove(ipa)
+				if err != nil {
+					t.Fatalf("delete IPA: %v", err)
+				}
+			}
+
+			d := &Dumper{opts: DumpOptions{Cache: cache, Force: tt.force}}
+
+			entry, ok := d.cached(tt.app)
+			if ok != tt.want {
+				t.Fatalf("cached() = %t, want %t", ok, tt.want)
+			}
+
+			if ok && entry.Path != ipa {
+				t.Errorf("cached() path = %q, want %q", entry.Path, ipa)
+			}
+		})
+	}
+}
+
+func TestCacheAdd(t *testing.T) {
+	app := &Application{Identifier: "com.example.app", Version: "1.0", Build: "100"}
+
+	cache, ipa := testCache(t, app)
+
+	// Re-dumping the same version and build replaces the entry
+	err := cache.Add(app, ipa)
+	if err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+
+	err = cache.Add(&Application{Identifier: "com.example.app", Version: "1.1", Build: "110"}, ipa)
+	if err != nil {
+		t.Fatalf("Add() = %v", err)
+	}
+
+	reopened, err := OpenCache(cache.path)
+	if err != nil {
+		t.Fatalf("OpenCache() = %v", err)
+	}
+
+	if len(reopened.entries) != 2 {
+		t.Errorf("OpenCache() has %d entries, want 2", len(reopened.entries))
+	}
+
+	if _, ok := reopened.Lookup(app); !ok {
+		t.Errorf("Lookup() = false, want true")
+	}
+}
+
+func TestOpenCacheMissing(t *testing.T) {
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "index.json"))
+	if err != nil {
+		t.Fatalf("OpenCache() = %v", err)
+	}
+
+	if _, ok := cache.Lookup(&Application{Identifier: "com.example.app"}); ok {
+		t.Errorf("Lookup() = true, want false")
+	}
+}
+
+func TestOpenCacheInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+
+	err := os.WriteFile(path, []byte("{"), 0644)
+	if err != nil {
+		t.Fatalf("write index: %v", err)
+	}
+
+	_, err = OpenCache(path)
+	if err == nil {
+		t.Errorf("OpenCache() succeeded, want error")
+	}
+}
+
+func TestCacheSaveError(t *testing.T) {
+	app := &Application{Identifier: "com.example.app", Version: "1.1", Build: "110"}
+
+	tests := []struct {
+		name  string                  // name is the name of the test.
+		block func(path string) error // block makes writing the index at path fail.
+	}{
+		{
+			name:  "write error",
+			block: func(path string) error { return os.MkdirAll(filepath.Join(path+".tmp", "blocked"), 0755) },
+		},
+		{
+			name: "rename error",
+			block: func(path string) error {
+				err := os.Remove(path)
+				if err != nil {
+					return err
+				}
+
+				return os.MkdirAll(filepath.Join(path, "blocked"), 0755)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, ipa := testCache(t, &Application{Identifier: "com.example.app", Version: "1.0", Build: "100"})
+
+			before, err := os.ReadFile(cache.path)
+			if err != nil {
+				t.Fatalf("read index: %v", err)
+			}
+
+			err = tt.block(cache.path)
+			if err != nil {
+				t.Fatalf("block index: %v", err)
+			}
+
+			err = cache.Add(app, ipa)
+			if err == nil {
+				t.Fatalf("Add() succeeded, want error")
+			}
+
+			// A failed write leaves neither a changed index nor a temporary file behind
+			if after, err := os.ReadFile(cache.path); err == nil && !bytes.Equal(after, before) {
+				t.Errorf("Add() changed the index")
+			}
+
+			if info, err := os.Stat(cache.path + ".tmp"); err == nil && info.Mode().IsRegular() {
+				t.Errorf("Add() left the temporary file behind")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package decrypt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+)
+
+// CleanupRules define what is removed from an app bundle before packaging it.
+type CleanupRules struct {
+	Files []string // Files are names of files removed from the app bundle's root directory.
+	Dirs  []string // Dirs are names of directories removed recursively, wherever they are in the app bundle.
+	Globs []string // Globs are path.Match patterns of paths (relative to the app bundle) that are removed recursively.
+}
+
+// DefaultCleanupRules returns the rules used if no others are configured.
+func DefaultCleanupRules() CleanupRules {
+	return CleanupRules{
+		Files: []string{
+			"iTunesMetadata.plist",     // Metadata file for iTunes
+			"embedded.mobileprovision", // Embedded provisioning profile
+		},
+		Dirs: []string{
+			"SC_Info",        // Provisioning profile information
+			"_CodeSignature", // Code signature information
+		},
+	}
+}
+
+// Add returns the rules extended by the other rules.
+func (r CleanupRules) Add(other CleanupRules) CleanupRules {
+	return CleanupRules{
+		Files: appendUnique(r.Files, other.Files...),
+		Dirs:  appendUnique(r.Dirs, other.Dirs...),
+		Globs: appendUnique(r.Globs, other.Globs...),
+	}
+}
+
+// Remove returns the rules without the given file names, directory names and glob patterns, so these are kept.
+func (r CleanupRules) Remove(names ...string) CleanupRules {
+	keep := func(s string) bool { return slices.Contains(names, s) }
+
+	return CleanupRules{
+		Files: slices.DeleteFunc(slices.Clone(r.Files), keep),
+		Dirs:  slices.DeleteFunc(slices.Clone(r.Dirs), keep),
+		Globs: slices.DeleteFunc(slices.Clone(r.Globs), keep),
+	}
+}
+
+// Validate returns an error if a glob pattern is malformed.
+func (r CleanupRules) Validate() error {
+	for _, glob := range r.Globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern [%s]: %w", glob, err)
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if the file or directory at name (relative to the app bundle) is to be removed.
+func (r CleanupRules) matches(name string, isDir bool) bool {
+	if isDir && slices.Contains(r.Dirs, path.Base(name)) {
+		return true
+	}
+
+	if !isDir && path.Dir(name) == "." && slices.Contains(r.Files, name) {
+		return true
+	}
+
+	for _, glob := range r.Globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanupAppBundle removes everything matching the rules from the app bundle fsys.
+func cleanupAppBundle(fsys WritableFS, rules CleanupRules) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip if not what we're looking for
+		if name == "." || !rules.matches(name, d.IsDir()) {
+			return nil
+		}
+
+		// Remove file or directory
+		err = fsys.RemoveAll(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("Failed to remove path", slog.String("path", name), slog.Any("error", err))
+		}
+
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+
+		return nil
+	})
+}
+
+// appendUnique appends all values to the slice that it doesn't contain yet.
+func appendUnique(s []string, values ...string) []string {
+	s = slices.Clone(s)
+
+	for _, v := range values {
+		if !slices.Contains(s, v) {
+			s = append(s, v)
+		}
+	}
+
+	return s
+}
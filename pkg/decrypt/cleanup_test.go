@@ -0,0 +1,152 @@
+package decrypt
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+)
+
+func TestCleanupAppBundle(t *testing.T) {
+	tests := []struct {
+		name  string       // name is the name of the test.
+		rules CleanupRules // rules are the cleanup rules applied.
+		want  []string     // want are the files left in the bundle.
+	}{
+		{
+			name:  "no rules",
+			rules: CleanupRules{},
+			want: []string{
+				"Ex", "Frameworks/A.framework/A", "Frameworks/A.framework/Info.plist", "Frameworks/B.dylib", "Info.plist",
+				"PlugIns/W.appex/W", "PlugIns/W.appex/_CodeSignature/Seal", "_CodeSignature/CodeResources",
+				"en.lproj/InfoPlist.strings", "en.lproj/Localizable.strings",
+			},
+		},
+		{
+			name:  "directories anywhere",
+			rules: CleanupRules{Dirs: []string{"_CodeSignature"}},
+			want: []string{
+				"Ex", "Frameworks/A.framework/A", "Frameworks/A.framework/Info.plist", "Frameworks/B.dylib", "Info.plist",
+				"PlugIns/W.appex/W", "en.lproj/InfoPlist.strings", "en.lproj/Localizable.strings",
+			},
+		},
+		{
+			name:  "files in root only",
+			rules: CleanupRules{Files: []string{"Info.plist"}},
+			want: []string{
+				"Ex", "Frameworks/A.framework/A", "Frameworks/A.framework/Info.plist", "Frameworks/B.dylib",
+				"PlugIns/W.appex/W", "PlugIns/W.appex/_CodeSignature/Seal", "_CodeSignature/CodeResources",
+				"en.lproj/InfoPlist.strings", "en.lproj/Localizable.strings",
+			},
+		},
+		{
+			name:  "globs",
+			rules: CleanupRules{Globs: []string{"*.lproj", "Frameworks/*.dylib", "PlugIns/*/_*"}},
+			want: []string{
+				"Ex", "Frameworks/A.framework/A", "Frameworks/A.framework/Info.plist", "Info.plist", "PlugIns/W.appex/W",
+				"_CodeSignature/CodeResources",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := MaskFS(testBundleFS())
+
+			err := cleanupAppBundle(fsys, tt.rules)
+			if err != nil {
+				t.Fatalf("cleanupAppBundle() = %v", err)
+			}
+
+			var got []string
+
+			err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					got = append(got, name)
+				}
+
+				return err
+			})
+
+			if err != nil {
+				t.Fatalf("WalkDir() = %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("cleanupAppBundle() left %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanupRulesMatches(t *testing.T) {
+	rules := DefaultCleanupRules().Add(CleanupRules{Globs: []string{"*.lproj", "PlugIns/*/Info.plist"}})
+
+	tests := []struct {
+		name  string // name is the path matched.
+		isDir bool   // isDir is true if the path is a directory.
+		want  bool   // want is true if the path is expected to be removed.
+	}{
+		{name: "iTunesMetadata.plist", isDir: false, want: true},
+		{name: "Frameworks/A.framework/iTunesMetadata.plist", isDir: false, want: false},
+		{name: "embedded.mobileprovision", isDir: false, want: true},
+		{name: "embedded.mobileprovision", isDir: true, want: false},
+		{name: "SC_Info", isDir: true, want: true},
+		{name: "_CodeSignature", isDir: true, want: true},
+		{name: "PlugIns/W.appex/_CodeSignature", isDir: true, want: true},
+		{name: "_CodeSignature", isDir: false, want: false},
+		{name: "en.lproj", isDir: true, want: true},
+		{name: "Frameworks/A.framework/en.lproj", isDir: true, want: false},
+		{name: "PlugIns/W.appex/Info.plist", isDir: false, want: true},
+		{name: "Info.plist", isDir: false, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := rules.matches(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("matches(%q, %t) = %t, want %t", tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestCleanupRulesAddRemove(t *testing.T) {
+	defaults := DefaultCleanupRules()
+
+	rules := defaults.Add(CleanupRules{Files: []string{"iTunesMetadata.plist", "PkgInfo"}, Globs: []string{"*.lproj"}})
+	rules = rules.Remove("embedded.mobileprovision", "_CodeSignature", "*.lproj")
+
+	want := CleanupRules{
+		Files: []string{"iTunesMetadata.plist", "PkgInfo"},
+		Dirs:  []string{"SC_Info"},
+		Globs: []string{},
+	}
+
+	if !slices.Equal(rules.Files, want.Files) || !slices.Equal(rules.Dirs, want.Dirs) || !slices.Equal(rules.Globs, want.Globs) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+
+	// The original rules must be left unchanged
+	if !slices.Equal(defaults.Files, DefaultCleanupRules().Files) || !slices.Equal(defaults.Dirs, DefaultCleanupRules().Dirs) {
+		t.Errorf("default rules changed to %+v", defaults)
+	}
+}
+
+func TestCleanupRulesValidate(t *testing.T) {
+	tests := []struct {
+		name    string   // name is the name of the test.
+		globs   []string // globs are the glob patterns validated.
+		wantErr bool     // wantErr is true if validation is expected to fail.
+	}{
+		{name: "no globs", globs: nil, wantErr: false},
+		{name: "valid globs", globs: []string{"*.lproj", "Frameworks/[A-Z]*.framework", "PlugIns/?.appex"}, wantErr: false},
+		{name: "unclosed bracket", globs: []string{"*.lproj", "Frameworks/[A-Z"}, wantErr: true},
+		{name: "trailing escape", globs: []string{"Frameworks\\"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CleanupRules{Globs: tt.globs}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package decrypt
+
+import (
+	"cmp"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// nestedBundleExtensions are the extensions of nested bundles with code, which get their own code signature.
+var nestedBundleExtensions = []string{".app", ".appex", ".framework", ".xpc"}
+
+// resourceRule is a rule of CodeResources, which determines how matching files are sealed.
+type resourceRule struct {
+	Pattern  *regexp.Regexp // Pattern is matched against paths relative to the bundle.
+	Omit     bool           // Omit excludes matching files from the seal.
+	Optional bool           // Optional allows matching files to be missing.
+	Weight   float64        // Weight is the precedence of the rule, the heaviest matching rule applies.
+}
+
+var (
+	// resourceRules are the rules for the legacy "files" seal.
+	resourceRules = []resourceRule{
+		{Pattern: regexp.MustCompile(`^.*`)},
+		{Pattern: regexp.MustCompile(`^.*\.lproj/`), Optional: true, Weight: 1000},
+		{Pattern: regexp.MustCompile(`^.*\.lproj/locversion.plist$`), Omit: true, Weight: 1100},
+		{Pattern: regexp.MustCompile(`^Base\.lproj/`), Weight: 1010},
+		{Pattern: regexp.MustCompile(`^version.plist$`)},
+	}
+
+	// resourceRules2 are the rules for the "files2" seal.
+	resourceRules2 = []resourceRule{
+		{Pattern: regexp.MustCompile(`.*\.dSYM($|/)`), Weight: 11},
+		{Pattern: regexp.MustCompile(`^(.*/)?\.DS_Store$`), Omit: true, Weight: 2000},
+		{Pattern: regexp.MustCompile(`^.*`)},
+		{Pattern: regexp.MustCompile(`^.*\.lproj/`), Optional: true, Weight: 1000},
+		{Pattern: regexp.MustCompile(`^.*\.lproj/locversion.plist$`), Omit: true, Weight: 1100},
+		{Pattern: regexp.MustCompile(`^Base\.lproj/`), Weight: 1010},
+		{Pattern: regexp.MustCompile(`^Info\.plist$`), Omit: true, Weight: 20},
+		{Pattern: regexp.MustCompile(`^PkgInfo$`), Omit: true, Weight: 20},
+		{Pattern: regexp.MustCompile(`^embedded\.provisionprofile$`), Weight: 20},
+		{Pattern: regexp.MustCompile(`^version\.plist$`), Weight: 20},
+	}
+)
+
+// resourceRulesPlist returns the rules as a CodeResources dictionary.
+func resourceRulesPlist(rules []resourceRule) map[string]any {
+	dict := make(map[string]any, len(rules))
+
+	for _, rule := range rules {
+		if !rule.Omit && !rule.Optional && rule.Weight == 0 {
+			dict[rule.Pattern.String()] = true
+			continue
+		}
+
+		value := make(map[string]any)
+
+		if rule.Omit {
+			value["omit"] = true
+		}
+
+		if rule.Optional {
+			value["optional"] = true
+		}
+
+		if rule.Weight != 0 {
+			value["weight"] = rule.Weight
+		}
+
+		dict[rule.Pattern.String()] = value
+	}
+
+	return dict
+}
+
+// matchResourceRule returns the heaviest rule matching name.
+func matchResourceRule(rules []resourceRule, name string) resourceRule {
+	var match resourceRule
+
+	for _, rule := range rules {
+		if rule.Weight >= match.Weight && rule.Pattern.MatchString(name) {
+			match = rule
+		}
+	}
+
+	return match
+}
+
+// AdhocSignBundle ad-hoc signs all unencrypted thin 64-bit Mach-O binaries in the local app bundle directory dir, and
+// regenerates "_CodeSignature/CodeResources" of the bundle and all nested bundles (e.g. frameworks and extensions).
+// Nested bundles are signed before the bundles containing them. The main executable of every bundle is taken from
+// CFBundleExecutable of its Info.plist. Binaries that are still encrypted are left unsigned with a warning. Bundles
+// whose main executable isn't a thin 64-bit Mach-O binary (e.g. fat frameworks and watchOS apps) are neither signed nor
+// sealed, also with a warning. It returns the hex encoded CDHashes of all signed binaries, by path relative to dir.
+func AdhocSignBundle(dir string, opts SignOptions) (map[string]string, error) {
+	fsys := DirFS(dir)
+
+	// Collect binaries
+	scan, err := scanBundle(fsys, false)
+	if err != nil {
+		return nil, fmt.Errorf("scan bundle: %w", err)
+	}
+
+	binaries := make([]string, 0, len(scan.Binaries))
+	encrypted := make(map[string]bool)
+
+	for _, binary := range scan.Binaries {
+		binaries = append(binaries, binary.Path)
+		encrypted[binary.Path] = binary.CryptID != 0
+	}
+
+	slices.Sort(binaries)
+
+	// Collect bundles with their main executables, nested ones first
+	executables := make(map[string]string)
+	unsupported := make(map[string]bool)
+
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() || (p != "." && !slices.Contains(nestedBundleExtensions, path.Ext(p))) {
+			return nil
+		}
+
+		executable, err := bundleExecutable(fsys, p)
+		if err != nil {
+			return fmt.Errorf("get main executable [%s]: %w", p, err)
+		}
+
+		if !slices.Contains(binaries, executable) {
+			if _, err := fs.Stat(fsys, executable); err != nil {
+				return fmt.Errorf("get main executable info [%s]: %w", executable, err)
+			}
+
+			slog.Warn("Main executable is not a thin 64-bit Mach-O binary, leaving bundle unsigned", slog.String("path", executable))
+			unsupported[p] = true
+		}
+
+		executables[p] = executable
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("walk directory: %w", err)
+	}
+
+	bundles := slices.Collect(maps.Keys(executables))
+
+	slices.SortFunc(bundles, func(a, b string) int {
+		return cmp.Or(strings.Count(b, "/")-strings.Count(a, "/"), strings.Compare(a, b))
+	})
+
+	// Sign bundles
+	cdhashes := make(map[string][]byte)
+
+	for _, bundle := range bundles {
+		executable := executables[bundle]
+
+		if unsupported[bundle] {
+			continue
+		}
+
+		// Sign loose binaries of the bundle (e.g. dylibs in "Frameworks")
+		for _, binary := range binaries {
+			if binary == executable || owningBundle(executables, binary) != bundle {
+				continue
+			}
+
+			if encrypted[binary] {
+				slog.Warn("Binary is encrypted, leaving it unsigned", slog.String("path", binary))
+				continue
+			}
+
+			cdhashes[binary], err = signBinary(dir, binary, signingIdentifier(binary), nil, opts)
+			if err != nil {
+				return nil, fmt.Errorf("sign binary [%s]: %w", binary, err)
+			}
+		}
+
+		// Seal resources
+		resources, err := writeCodeResources(dir, bundle, executables, cdhashes)
+		if err != nil {
+			return nil, fmt.Errorf("write code resources [%s]: %w", bundle, err)
+		}
+
+		// Sign main executable, binding Info.plist and resources
+		if encrypted[executable] {
+			slog.Warn("Binary is encrypted, leaving it unsigned", slog.String("path", executable))
+			continue
+		}
+
+		special := map[uint32][]byte{csSlotResourceDir: sha256Sum(resources)}
+
+		info, err := fs.ReadFile(fsys, path.Join(bundle, "Info.plist"))
+		if err == nil {
+			special[csSlotInfo] = sha256Sum(info)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("read Info.plist [%s]: %w", bundle, err)
+		}
+
+		cdhashes[executable], err = signBinary(dir, executable, signingIdentifier(executable), special, opts)
+		if err != nil {
+			return nil, fmt.Errorf("sign binary [%s]: %w", executable, err)
+		}
+	}
+
+	// Encode CDHashes
+	result := make(map[string]string, len(cdhashes))
+
+	for binary, cdhash := range cdhashes {
+		result[binary] = hex.EncodeToString(cdhash)
+	}
+
+	return result, nil
+}
+
+// bundleExecutable returns the path of the main executable of the bundle at dir in fsys, as named by CFBundleExecutable
+// of its (XML or binary) Info.plist.
+func bundleExecutable(fsys fs.FS, dir string) (string, error) {
+	data, err := fs.ReadFile(fsys, path.Join(dir, "Info.plist"))
+	if err != nil {
+		return "", fmt.Errorf("read Info.plist: %w", err)
+	}
+
+	value, err := parsePlist(data)
+	if err != nil {
+		return "", fmt.Errorf("parse Info.plist: %w", err)
+	}
+
+	dict, _ := value.(map[string]any)
+
+	name, _ := dict["CFBundleExecutable"].(string)
+	if name == "" || strings.Contains(name, "/") || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid CFBundleExecutable [%s]", name)
+	}
+
+	return path.Join(dir, name), nil
+}
+
+// owningBundle returns the innermost bundle containing name.
+func owningBundle(executables map[string]string, name string) string {
+	owner := "."
+
+	for bundle := range executables {
+		if strings.HasPrefix(name, bundle+"/") && len(bundle) > len(owner) {
+			owner = bundle
+		}
+	}
+
+	return owner
+}
+
+// writeCodeResources seals the resources of bundle in the local directory dir, and writes them to
+// "_CodeSignature/CodeResources" of the bundle. Nested bundles and loose binaries are sealed by the CDHashes of their
+// signatures, which must already be in cdhashes. Loose binaries that haven't been signed are sealed like other files,
+// nested bundles with unsigned main executables aren't sealed. It returns the content of the written file.
+func writeCodeResources(dir string, bundle string, executables map[string]string, cdhashes map[string][]byte) ([]byte, error) {
+	fsys := DirFS(dir)
+	files, files2 := make(map[string]any), make(map[string]any)
+
+	err := fs.WalkDir(fsys, bundle, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == bundle {
+			return nil
+		}
+
+		name := strings.TrimPrefix(p, bundle+"/")
+		if bundle == "." {
+			name = p
+		}
+
+		// Skip own signature and main executable
+		if name == "_CodeSignature" || p == executables[bundle] {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		// Seal nested bundles and loose binaries by their CDHash (nested bundles with unsigned executables can't be)
+		if _, ok := executables[p]; ok {
+			if cdhash, ok := cdhashes[executables[p]]; ok {
+				files2[name] = nestedCodeSeal(cdhash)
+			}
+
+			return fs.SkipDir
+		}
+
+		if cdhash, ok := cdhashes[p]; ok {
+			files2[name] = nestedCodeSeal(cdhash)
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rule, rule2 := matchResourceRule(resourceRules, name), matchResourceRule(resourceRules2, name)
+
+		// Seal symbolic links by their target
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(filepath.Join(dir, filepath.FromSlash(p)))
+			if err != nil {
+				return fmt.Errorf("read symbolic link [%s]: %w", p, err)
+			}
+
+			if !rule2.Omit {
+				files2[name] = map[string]any{"symlink": target}
+			}
+
+			return nil
+		}
+
+		// Seal files by their hashes
+		hash1, hash2, err := hashFile(fsys, p)
+		if err != nil {
+			return fmt.Errorf("hash file [%s]: %w", p, err)
+		}
+
+		if !rule.Omit {
+			if rule.Optional {
+				files[name] = map[string]any{"hash": hash1, "optional": true}
+			} else {
+				files[name] = hash1
+			}
+		}
+
+		if !rule2.Omit {
+			seal := map[string]any{"hash": hash1, "hash2": hash2}
+
+			if rule2.Optional {
+				seal["optional"] = true
+			}
+
+			files2[name] = seal
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("walk directory: %w", err)
+	}
+
+	// Encode and write CodeResources
+	content, err := encodeXMLPlist(map[string]any{
+		"files":  files,
+		"files2": files2,
+		"rules":  resourceRulesPlist(resourceRules),
+		"rules2": resourceRulesPlist(resourceRules2),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("encode code resources: %w", err)
+	}
+
+	signatureDir := filepath.Join(dir, filepath.FromSlash(bundle), "_CodeSignature")
+
+	err = os.MkdirAll(signatureDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("create signature directory: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(signatureDir, "CodeResources"), content, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("write file: %w", err)
+	}
+
+	return content, nil
+}
+
+// nestedCodeSeal returns the seal of nested code with an ad-hoc signature, which is designated by its CDHash.
+func nestedCodeSeal(cdhash []byte) map[string]any {
+	return map[string]any{
+		"cdhash":      cdhash,
+		"requirement": fmt.Sprintf("cdhash H\"%s\"", hex.EncodeToString(cdhash)),
+	}
+}
+
+// hashFile returns the SHA-1 and SHA-256 hashes of a file in fsys.
+func hashFile(fsys fs.FS, name string) ([]byte, []byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open file: %w", err)
+	}
+
+	defer file.Close()
+
+	h1, h256 := sha1.New(), sha256.New()
+
+	_, err = io.Copy(io.MultiWriter(h1, h256), file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read file: %w", err)
+	}
+
+	return h1.Sum(nil), h256.Sum(nil), nil
+}
@@ -0,0 +1,115 @@
+package decrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testInfoPlist returns an XML Info.plist with the given main executable.
+func testInfoPlist(executable string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>CFBundleExecutable</key><string>` + executable + `</string></dict></plist>`
+}
+
+// testBundle writes files to a new local app bundle directory, by slash-separated path.
+func testBundle(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("create directory [%s]: %v", name, err)
+		}
+
+		err = os.WriteFile(path, content, 0755)
+		if err != nil {
+			t.Fatalf("write file [%s]: %v", name, err)
+		}
+	}
+
+	return dir
+}
+
+func TestAdhocSignBundle(t *testing.T) {
+	// Fat binary with a single arm64 slice
+	fat := binary.BigEndian.AppendUint32(nil, 0xCAFEBABE)
+	fat = binary.BigEndian.AppendUint32(fat, 1)
+	fat = append(fat, make([]byte, 0x1000)...)
+
+	// Thin 32-bit binary for arm64_32 (watchOS)
+	arm6432 := binary.LittleEndian.AppendUint32(nil, 0xFEEDFACE)
+	arm6432 = binary.LittleEndian.AppendUint32(arm6432, 0x0200000C)
+	arm6432 = append(arm6432, make([]byte, 0x1000)...)
+
+	dir := testBundle(t, map[string][]byte{
+		"Info.plist":                        []byte(testInfoPlist("Ex")),
+		"Ex":                                testBinary(MH_EXECUTE, 0x120, nil, 0),
+		"Frameworks/A.framework/Info.plist": []byte(testInfoPlist("A")),
+		"Frameworks/A.framework/A":          testBinary(MH_DYLIB, 0x120, nil, 0),
+		"Frameworks/F.framework/Info.plist": []byte(testInfoPlist("F")),
+		"Frameworks/F.framework/F":          fat,
+		"Watch/W.app/Info.plist":            []byte(testInfoPlist("W")),
+		"Watch/W.app/W":                     arm6432,
+	})
+
+	cdhashes, err := AdhocSignBundle(dir, SignOptions{})
+	if err != nil {
+		t.Fatalf("AdhocSignBundle() = %v", err)
+	}
+
+	// Only thin 64-bit main executables are signed
+	var signed []string
+
+	for name := range cdhashes {
+		signed = append(signed, name)
+	}
+
+	slices.Sort(signed)
+
+	if want := []string{"Ex", "Frameworks/A.framework/A"}; !slices.Equal(signed, want) {
+		t.Errorf("AdhocSignBundle() signed %q, want %q", signed, want)
+	}
+
+	// Bundles with other main executables are neither signed nor sealed
+	for name, want := range map[string][]byte{"Frameworks/F.framework/F": fat, "Watch/W.app/W": arm6432} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("read binary [%s]: %v", name, err)
+		}
+
+		if !bytes.Equal(data, want) {
+			t.Errorf("AdhocSignBundle() modified [%s]", name)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(filepath.Dir(name)), "_CodeSignature")); err == nil {
+			t.Errorf("AdhocSignBundle() sealed the bundle of [%s]", name)
+		}
+	}
+
+	for _, bundle := range []string{".", "Frameworks/A.framework"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(bundle), "_CodeSignature", "CodeResources")); err != nil {
+			t.Errorf("AdhocSignBundle() didn't seal [%s]: %v", bundle, err)
+		}
+	}
+}
+
+func TestAdhocSignBundleMissingExecutable(t *testing.T) {
+	dir := testBundle(t, map[string][]byte{
+		"Info.plist":                        []byte(testInfoPlist("Ex")),
+		"Ex":                                testBinary(MH_EXECUTE, 0x120, nil, 0),
+		"Frameworks/A.framework/Info.plist": []byte(testInfoPlist("A")),
+	})
+
+	_, err := AdhocSignBundle(dir, SignOptions{})
+	if err == nil {
+		t.Errorf("AdhocSignBundle() succeeded, want error")
+	}
+}
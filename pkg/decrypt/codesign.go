@@ -0,0 +1,473 @@
+package decrypt
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/fs"
+)
+
+const (
+	// csMagicEmbeddedSignature is the magic of the SuperBlob that contains all code signature blobs.
+	csMagicEmbeddedSignature = 0xFADE0CC0
+
+	// csMagicCodeDirectory is the magic of a CodeDirectory blob.
+	csMagicCodeDirectory = 0xFADE0C02
+
+	// csMagicRequirements is the magic of the SuperBlob with all internal requirements.
+	csMagicRequirements = 0xFADE0C01
+
+	// csMagicEmbeddedEntitlements is the magic of the blob with XML entitlements.
+	csMagicEmbeddedEntitlements = 0xFADE7171
+
+	// csMagicEmbeddedDEREntitlements is the magic of the blob with DER entitlements.
+	csMagicEmbeddedDEREntitlements = 0xFADE7172
+
+	// csMagicBlobWrapper is the magic of the blob wrapping the CMS signature (empty for ad-hoc signatures).
+	csMagicBlobWrapper = 0xFADE0B01
+
+	// csSlotCodeDirectory is the slot of the primary CodeDirectory.
+	csSlotCodeDirectory = 0
+
+	// csSlotInfo is the special slot of the hash of the bundle's Info.plist.
+	csSlotInfo = 1
+
+	// csSlotRequirements is the slot of the requirements blob.
+	csSlotRequirements = 2
+
+	// csSlotResourceDir is the special slot of the hash of the bundle's _CodeSignature/CodeResources.
+	csSlotResourceDir = 3
+
+	// csSlotEntitlements is the slot of the blob with XML entitlements.
+	csSlotEntitlements = 5
+
+	// csSlotDEREntitlements is the slot of the blob with DER entitlements.
+	csSlotDEREntitlements = 7
+
+	// csSlotAlternateCodeDirectories is the first slot of alternate CodeDirectories (up to 5).
+	csSlotAlternateCodeDirectories = 0x1000
+
+	// csSlotSignature is the slot of the CMS signature.
+	csSlotSignature = 0x10000
+
+	// maxCodeSignatureSize is the maximum size of a code signature read from a binary (page hashes of a 1 GiB binary
+	// take 8 MiB per CodeDirectory).
+	maxCodeSignatureSize = 64 << 20
+)
+
+var (
+	// csHashTypes maps CodeDirectory hash types to their names.
+	csHashTypes = map[uint8]string{1: "sha1", 2: "sha256", 3: "sha256-truncated", 4: "sha384"}
+
+	// csRequirementTypes maps requirement types to their names.
+	csRequirementTypes = map[uint32]string{1: "host", 2: "guest", 3: "designated", 4: "library", 5: "plugin"}
+)
+
+// CodeDirectory describes a CodeDirectory of a code signature, which holds the hashes of all code pages.
+type CodeDirectory struct {
+	Version    uint32 `json:"version"`          // Version is the version of the CodeDirectory format.
+	Flags      uint32 `json:"flags"`            // Flags are the code signing flags (e.g. 0x2 for ad-hoc signatures).
+	Identifier string `json:"identifier"`       // Identifier is the signing identifier (usually the bundle ID).
+	TeamID     string `json:"teamId,omitempty"` // TeamID is the identifier of the signing team.
+	HashType   string `json:"hashType"`         // HashType is the hash algorithm of the page hashes (e.g. "sha256").
+	PageSize   int    `json:"pageSize"`         // PageSize is the size of hashed code pages.
+	CodeSlots  int    `json:"codeSlots"`        // CodeSlots is the number of hashed code pages.
+	CDHash     string `json:"cdhash"`           // CDHash is the hex encoded (truncated) hash of the CodeDirectory.
+
+	hashType uint8 // hashType is the raw hash type.
+}
+
+// Requirement is an internal requirement of a code signature, in compiled form.
+type Requirement struct {
+	Type string `json:"type"` // Type is the requirement type (e.g. "designated").
+	Data []byte `json:"data"` // Data is the compiled requirement expression.
+}
+
+// CodeSignature describes the embedded code signature of a Mach-O binary.
+type CodeSignature struct {
+	CodeDirectories []CodeDirectory `json:"codeDirectories"`           // CodeDirectories are the primary and alternate ones.
+	Requirements    []Requirement   `json:"requirements,omitempty"`    // Requirements are the internal requirements.
+	Entitlements    map[string]any  `json:"entitlements,omitempty"`    // Entitlements are from the XML entitlements blob.
+	DEREntitlements map[string]any  `json:"derEntitlements,omitempty"` // DEREntitlements are from the DER entitlements blob.
+	Signed          bool            `json:"signed"`                    // Signed is true if a CMS signature is present (false for ad-hoc).
+}
+
+// CodeDirectory returns the CodeDirectory with the strongest hash type, which determines the CDHash of the binary.
+func (cs *CodeSignature) CodeDirectory() *CodeDirectory {
+	var best *CodeDirectory
+
+	for i := range cs.CodeDirectories {
+		if cd := &cs.CodeDirectories[i]; best == nil || cd.hashType > best.hashType {
+			best = cd
+		}
+	}
+
+	return best
+}
+
+// codeSignatureBlobs parses a code signature SuperBlob, and returns all blobs (including their magic and length) by
+// slot. All values are big-endian.
+func codeSignatureBlobs(data []byte) (map[uint32][]byte, error) {
+	// Parse SuperBlob header
+	if len(data) < 12 {
+		return nil, fmt.Errorf("code signature too short: %d bytes", len(data))
+	}
+
+	magic := binary.BigEndian.Uint32(data[0:])
+	length := binary.BigEndian.Uint32(data[4:])
+	count := binary.BigEndian.Uint32(data[8:])
+
+	if magic != csMagicEmbeddedSignature {
+		return nil, fmt.Errorf("invalid code signature magic: 0x%08x", magic)
+	}
+
+	if int(length) > len(data) || 12+int(count)*8 > int(length) {
+		return nil, fmt.Errorf("invalid code signature length: %d bytes", length)
+	}
+
+	data = data[:length]
+
+	// Parse blob index
+	blobs := make(map[uint32][]byte, count)
+
+	for i := range int(count) {
+		slot := binary.BigEndian.Uint32(data[12+i*8:])
+		offset := binary.BigEndian.Uint32(data[16+i*8:])
+
+		if int(offset)+8 > len(data) {
+			return nil, fmt.Errorf("invalid offset of blob [%d]: %d", slot, offset)
+		}
+
+		blobLength := binary.BigEndian.Uint32(data[offset+4:])
+		if blobLength < 8 || int(offset)+int(blobLength) > len(data) {
+			return nil, fmt.Errorf("invalid length of blob [%d]: %d bytes", slot, blobLength)
+		}
+
+		blobs[slot] = data[offset : offset+blobLength]
+	}
+
+	return blobs, nil
+}
+
+// ParseCodeSignature parses the code signature SuperBlob of a Mach-O binary.
+func ParseCodeSignature(data []byte) (*CodeSignature, error) {
+	blobs, err := codeSignatureBlobs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &CodeSignature{}
+
+	// Parse primary and alternate CodeDirectories
+	for _, slot := range []uint32{csSlotCodeDirectory, csSlotAlternateCodeDirectories, csSlotAlternateCodeDirectories + 1,
+		csSlotAlternateCodeDirectories + 2, csSlotAlternateCodeDirectories + 3, csSlotAlternateCodeDirectories + 4} {
+		blob, ok := blobs[slot]
+		if !ok {
+			continue
+		}
+
+		cd, err := parseCodeDirectory(blob)
+		if err != nil {
+			return nil, fmt.Errorf("parse code directory [0x%x]: %w", slot, err)
+		}
+
+		cs.CodeDirectories = append(cs.CodeDirectories, *cd)
+	}
+
+	if len(cs.CodeDirectories) == 0 {
+		return nil, fmt.Errorf("no code directory")
+	}
+
+	// Parse requirements
+	if blob, ok := blobs[csSlotRequirements]; ok {
+		cs.Requirements, err = parseRequirements(blob)
+		if err != nil {
+			return nil, fmt.Errorf("parse requirements: %w", err)
+		}
+	}
+
+	// Parse XML entitlements
+	if blob, ok := blobs[csSlotEntitlements]; ok && binary.BigEndian.Uint32(blob) == csMagicEmbeddedEntitlements {
+		value, err := parseXMLPlist(blob[8:])
+		if err != nil {
+			return nil, fmt.Errorf("parse entitlements: %w", err)
+		}
+
+		cs.Entitlements, ok = value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("entitlements are not a dictionary")
+		}
+	}
+
+	// Parse DER entitlements
+	if blob, ok := blobs[csSlotDEREntitlements]; ok && binary.BigEndian.Uint32(blob) == csMagicEmbeddedDEREntitlements {
+		cs.DEREntitlements, err = parseDEREntitlements(blob[8:])
+		if err != nil {
+			return nil, fmt.Errorf("parse DER entitlements: %w", err)
+		}
+	}
+
+	// Check for a CMS signature (ad-hoc signatures have an empty one)
+	if blob, ok := blobs[csSlotSignature]; ok && len(blob) > 8 {
+		cs.Signed = true
+	}
+
+	return cs, nil
+}
+
+// parseCodeDirectory parses a CodeDirectory blob.
+func parseCodeDirectory(blob []byte) (*CodeDirectory, error) {
+	if len(blob) < 44 || binary.BigEndian.Uint32(blob) != csMagicCodeDirectory {
+		return nil, fmt.Errorf("invalid code directory")
+	}
+
+	cd := &CodeDirectory{
+		Version:   binary.BigEndian.Uint32(blob[8:]),
+		Flags:     binary.BigEndian.Uint32(blob[12:]),
+		CodeSlots: int(binary.BigEndian.Uint32(blob[28:])),
+		PageSize:  1 << blob[39],
+	}
+
+	// Hash type
+	cd.hashType = blob[37]
+
+	cd.HashType = csHashTypes[cd.hashType]
+	if cd.HashType == "" {
+		cd.HashType = fmt.Sprintf("0x%x", cd.hashType)
+	}
+
+	// Identifier and team ID (since version 0x20200)
+	cd.Identifier = cString(blob, binary.BigEndian.Uint32(blob[20:]))
+
+	if cd.Version >= 0x20200 && len(blob) >= 52 {
+		if teamOffset := binary.BigEndian.Uint32(blob[48:]); teamOffset != 0 {
+			cd.TeamID = cString(blob, teamOffset)
+		}
+	}
+
+	// CDHash
+	var h hash.Hash
+
+	switch cd.hashType {
+	case 1:
+		h = sha1.New()
+	case 2, 3:
+		h = sha256.New()
+	case 4:
+		h = sha512.New384()
+	}
+
+	if h != nil {
+		h.Write(blob)
+		cd.CDHash = hex.EncodeToString(h.Sum(nil)[:20])
+	}
+
+	return cd, nil
+}
+
+// parseRequirements parses the SuperBlob with all internal requirements.
+func parseRequirements(blob []byte) ([]Requirement, error) {
+	if len(blob) < 12 || binary.BigEndian.Uint32(blob) != csMagicRequirements {
+		return nil, fmt.Errorf("invalid requirements")
+	}
+
+	count := int(binary.BigEndian.Uint32(blob[8:]))
+	if 12+count*8 > len(blob) {
+		return nil, fmt.Errorf("invalid number of requirements: %d", count)
+	}
+
+	var reqs []Requirement
+
+	for i := range count {
+		t := binary.BigEndian.Uint32(blob[12+i*8:])
+		offset := binary.BigEndian.Uint32(blob[16+i*8:])
+
+		if int(offset)+8 > len(blob) {
+			return nil, fmt.Errorf("invalid offset of requirement [%d]: %d", t, offset)
+		}
+
+		length := binary.BigEndian.Uint32(blob[offset+4:])
+		if length < 8 || int(offset)+int(length) > len(blob) {
+			return nil, fmt.Errorf("invalid length of requirement [%d]: %d bytes", t, length)
+		}
+
+		name := csRequirementTypes[t]
+		if name == "" {
+			name = fmt.Sprintf("0x%x", t)
+		}
+
+		reqs = append(reqs, Requirement{Type: name, Data: blob[offset+8 : offset+length]})
+	}
+
+	return reqs, nil
+}
+
+// parseDEREntitlements parses DER encoded entitlements, which are a version number followed by a dictionary.
+func parseDEREntitlements(data []byte) (map[string]any, error) {
+	var outer asn1.RawValue
+
+	_, err := asn1.Unmarshal(data, &outer)
+	if err != nil {
+		return nil, err
+	}
+
+	if outer.Class != asn1.ClassApplication || outer.Tag != 16 {
+		return nil, fmt.Errorf("unexpected tag [%d/%d]", outer.Class, outer.Tag)
+	}
+
+	// Skip version
+	var version int
+
+	rest, err := asn1.Unmarshal(outer.Bytes, &version)
+	if err != nil {
+		return nil, fmt.Errorf("parse version: %w", err)
+	}
+
+	// Parse dictionary
+	var dict asn1.RawValue
+
+	_, err = asn1.Unmarshal(rest, &dict)
+	if err != nil {
+		return nil, fmt.Errorf("parse dictionary: %w", err)
+	}
+
+	value, err := derValue(dict)
+	if err != nil {
+		return nil, err
+	}
+
+	entitlements, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("entitlements are not a dictionary")
+	}
+
+	return entitlements, nil
+}
+
+// derValue converts a DER encoded entitlements value. Dictionaries are context-specific sets of key-value sequences,
+// and arrays are sequences.
+func derValue(raw asn1.RawValue) (any, error) {
+	switch {
+	case raw.Class == asn1.ClassContextSpecific && raw.Tag == 16:
+		dict := make(map[string]any)
+
+		for rest := raw.Bytes; len(rest) > 0; {
+			var entry struct {
+				Key   string `asn1:"utf8"`
+				Value asn1.RawValue
+			}
+
+			var err error
+
+			rest, err = asn1.Unmarshal(rest, &entry)
+			if err != nil {
+				return nil, fmt.Errorf("parse dictionary entry: %w", err)
+			}
+
+			dict[entry.Key], err = derValue(entry.Value)
+			if err != nil {
+				return nil, fmt.Errorf("parse value [%s]: %w", entry.Key, err)
+			}
+		}
+
+		return dict, nil
+
+	case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagSequence:
+		array := []any{}
+
+		for rest := raw.Bytes; len(rest) > 0; {
+			var elem asn1.RawValue
+
+			var err error
+
+			rest, err = asn1.Unmarshal(rest, &elem)
+			if err != nil {
+				return nil, fmt.Errorf("parse array element: %w", err)
+			}
+
+			value, err := derValue(elem)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		return array, nil
+
+	case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagBoolean:
+		var b bool
+		_, err := asn1.Unmarshal(raw.FullBytes, &b)
+		return b, err
+
+	case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagInteger:
+		var i int64
+		_, err := asn1.Unmarshal(raw.FullBytes, &i)
+		return i, err
+
+	case raw.Class == asn1.ClassUniversal && raw.Tag == asn1.TagUTF8String:
+		return string(raw.Bytes), nil
+
+	default:
+		return nil, fmt.Errorf("unexpected tag [%d/%d]", raw.Class, raw.Tag)
+	}
+}
+
+// cString returns the NUL terminated string at offset in data.
+func cString(data []byte, offset uint32) string {
+	if int(offset) >= len(data) {
+		return ""
+	}
+
+	s := data[offset:]
+
+	for i, c := range s {
+		if c == 0 {
+			return string(s[:i])
+		}
+	}
+
+	return string(s)
+}
+
+// ReadCodeSignature reads and parses the code signature of a binary in fsys. It returns nil if the binary is not
+// signed.
+func ReadCodeSignature(fsys fs.FS, info *MachOInfo) (*CodeSignature, error) {
+	data, err := readCodeSignature(fsys, info)
+	if err != nil {
+		return nil, fmt.Errorf("read code signature: %w", err)
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	return ParseCodeSignature(data)
+}
+
+// readCodeSignature reads the code signature of a binary in fsys. It returns nil if the binary is not signed.
+func readCodeSignature(fsys fs.FS, info *MachOInfo) ([]byte, error) {
+	if info.CodeSigCommandOffset == 0 || info.CodeSigSize == 0 {
+		return nil, nil
+	}
+
+	if info.CodeSigSize > maxCodeSignatureSize {
+		return nil, fmt.Errorf("code signature too large: %d bytes", info.CodeSigSize)
+	}
+
+	return readFileRange(fsys, info.Path, int64(info.CodeSigOffset), int(info.CodeSigSize))
+}
+
+// EffectiveEntitlements returns the entitlements of the code signature, preferring the XML ones over the DER ones.
+func (cs *CodeSignature) EffectiveEntitlements() map[string]any {
+	if cs.Entitlements != nil {
+		return cs.Entitlements
+	}
+
+	return cs.DEREntitlements
+}
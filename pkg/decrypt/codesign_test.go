@@ -0,0 +1,285 @@
+package decrypt
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"testing/fstest"
+)
+
+// testSlotBlob is a blob of a code signature SuperBlob in a test.
+type testSlotBlob struct {
+	Slot uint32 // Slot is the slot of the blob.
+	Blob []byte // Blob is the blob, including its magic and length.
+}
+
+// testBlob builds a code signature blob with the given magic and payload.
+func testBlob(magic uint32, payload []byte) []byte {
+	blob := binary.BigEndian.AppendUint32(nil, magic)
+	blob = binary.BigEndian.AppendUint32(blob, uint32(8+len(payload)))
+
+	return append(blob, payload...)
+}
+
+// testSuperBlob builds a SuperBlob with the given magic and blobs.
+func testSuperBlob(magic uint32, blobs ...testSlotBlob) []byte {
+	offset := 12 + 8*len(blobs)
+
+	var index, payload []byte
+
+	for _, b := range blobs {
+		index = binary.BigEndian.AppendUint32(index, b.Slot)
+		index = binary.BigEndian.AppendUint32(index, uint32(offset+len(payload)))
+		payload = append(payload, b.Blob...)
+	}
+
+	data := binary.BigEndian.AppendUint32(nil, magic)
+	data = binary.BigEndian.AppendUint32(data, uint32(offset+len(payload)))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(blobs)))
+
+	return append(append(data, index...), payload...)
+}
+
+// testCodeDirectory builds a CodeDirectory blob without hashes.
+func testCodeDirectory(identifier string, teamID string, hashType uint8, codeSlots uint32) []byte {
+	cd := make([]byte, 88)
+
+	binary.BigEndian.PutUint32(cd[0:], csMagicCodeDirectory)
+	binary.BigEndian.PutUint32(cd[8:], 0x20400)
+	binary.BigEndian.PutUint32(cd[12:], csFlagAdhoc)
+	binary.BigEndian.PutUint32(cd[20:], uint32(len(cd)))
+	binary.BigEndian.PutUint32(cd[28:], codeSlots)
+	cd[37] = hashType
+	cd[39] = 12
+
+	cd = append(append(cd, identifier...), 0)
+
+	if teamID != "" {
+		binary.BigEndian.PutUint32(cd[48:], uint32(len(cd)))
+		cd = append(append(cd, teamID...), 0)
+	}
+
+	binary.BigEndian.PutUint32(cd[4:], uint32(len(cd)))
+
+	return cd
+}
+
+// testDEREntitlements builds DER entitlements with a single boolean entitlement.
+func testDEREntitlements(t *testing.T, key string, value bool) []byte {
+	t.Helper()
+
+	entry, err := asn1.Marshal(struct {
+		Key   string `asn1:"utf8"`
+		Value bool
+	}{Key: key, Value: value})
+
+	if err != nil {
+		t.Fatalf("marshal entry: %v", err)
+	}
+
+	version, err := asn1.Marshal(1)
+	if err != nil {
+		t.Fatalf("marshal version: %v", err)
+	}
+
+	dict, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 16, IsCompound: true, Bytes: entry})
+	if err != nil {
+		t.Fatalf("marshal dictionary: %v", err)
+	}
+
+	data, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassApplication, Tag: 16, IsCompound: true, Bytes: append(version, dict...)})
+	if err != nil {
+		t.Fatalf("marshal entitlements: %v", err)
+	}
+
+	return data
+}
+
+func TestCodeSignatureBlobs(t *testing.T) {
+	entitlements := testBlob(csMagicEmbeddedEntitlements, []byte("entitlements"))
+
+	valid := testSuperBlob(csMagicEmbeddedSignature,
+		testSlotBlob{Slot: csSlotEntitlements, Blob: entitlements},
+		testSlotBlob{Slot: csSlotSignature, Blob: testBlob(csMagicBlobWrapper, nil)})
+
+	// corrupt returns a copy of valid with a big-endian value replaced at offset.
+	corrupt := func(offset int, value uint32) []byte {
+		data := bytes.Clone(valid)
+		binary.BigEndian.PutUint32(data[offset:], value)
+
+		return data
+	}
+
+	tests := []struct {
+		name    string // name is the name of the test.
+		data    []byte // data is the parsed SuperBlob.
+		wantErr bool   // wantErr is true if parsing is expected to fail.
+	}{
+		{name: "valid", data: valid, wantErr: false},
+		{name: "trailing padding", data: append(bytes.Clone(valid), make([]byte, 16)...), wantErr: false},
+		{name: "too short", data: valid[:8], wantErr: true},
+		{name: "invalid magic", data: corrupt(0, csMagicEmbeddedEntitlements), wantErr: true},
+		{name: "length exceeds data", data: valid[:len(valid)-1], wantErr: true},
+		{name: "index exceeds length", data: corrupt(8, 0x10000000), wantErr: true},
+		{name: "blob offset exceeds length", data: corrupt(16, uint32(len(valid))), wantErr: true},
+		{name: "blob length exceeds length", data: corrupt(28+4, 0x1000), wantErr: true},
+		{name: "blob length too small", data: corrupt(28+4, 4), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blobs, err := codeSignatureBlobs(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("codeSignatureBlobs() = %v, want error", blobs)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("codeSignatureBlobs() = %v", err)
+			}
+
+			if !bytes.Equal(blobs[csSlotEntitlements], entitlements) || len(blobs[csSlotSignature]) != 8 {
+				t.Errorf("codeSignatureBlobs() = %x", blobs)
+			}
+		})
+	}
+}
+
+func TestParseCodeSignature(t *testing.T) {
+	cd1 := testCodeDirectory("com.example.ex", "TEAM123456", 1, 3)
+	cd256 := testCodeDirectory("com.example.ex", "TEAM123456", 2, 3)
+
+	requirements := testSuperBlob(csMagicRequirements,
+		testSlotBlob{Slot: 3, Blob: testBlob(0xFADE0C00, []byte{0, 0, 0, 1, 0, 0, 0, 3})})
+
+	entitlements := testBlob(csMagicEmbeddedEntitlements, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>application-identifier</key><string>TEAM123456.com.example.ex</string></dict></plist>`))
+
+	derEntitlements := testBlob(csMagicEmbeddedDEREntitlements, testDEREntitlements(t, "get-task-allow", true))
+
+	data := testSuperBlob(csMagicEmbeddedSignature,
+		testSlotBlob{Slot: csSlotCodeDirectory, Blob: cd1},
+		testSlotBlob{Slot: csSlotRequirements, Blob: requirements},
+		testSlotBlob{Slot: csSlotEntitlements, Blob: entitlements},
+		testSlotBlob{Slot: csSlotDEREntitlements, Blob: derEntitlements},
+		testSlotBlob{Slot: csSlotAlternateCodeDirectories, Blob: cd256},
+		testSlotBlob{Slot: csSlotSignature, Blob: testBlob(csMagicBlobWrapper, []byte{0x30, 0x00})})
+
+	cs, err := ParseCodeSignature(data)
+	if err != nil {
+		t.Fatalf("ParseCodeSignature() = %v", err)
+	}
+
+	// CodeDirectories
+	if len(cs.CodeDirectories) != 2 {
+		t.Fatalf("code directories = %+v, want 2", cs.CodeDirectories)
+	}
+
+	sum1, sum256 := sha1.Sum(cd1), sha256.Sum256(cd256)
+
+	for i, want := range []CodeDirectory{
+		{Version: 0x20400, Flags: csFlagAdhoc, Identifier: "com.example.ex", TeamID: "TEAM123456", HashType: "sha1", PageSize: 4096, CodeSlots: 3, CDHash: hex.EncodeToString(sum1[:20]), hashType: 1},
+		{Version: 0x20400, Flags: csFlagAdhoc, Identifier: "com.example.ex", TeamID: "TEAM123456", HashType: "sha256", PageSize: 4096, CodeSlots: 3, CDHash: hex.EncodeToString(sum256[:20]), hashType: 2},
+	} {
+		if cs.CodeDirectories[i] != want {
+			t.Errorf("code directory [%d] = %+v, want %+v", i, cs.CodeDirectories[i], want)
+		}
+	}
+
+	if cd := cs.CodeDirectory(); cd.HashType != "sha256" {
+		t.Errorf("CodeDirectory() = %+v, want the sha256 one", cd)
+	}
+
+	// Requirements, entitlements and signature
+	if len(cs.Requirements) != 1 || cs.Requirements[0].Type != "designated" || !bytes.Equal(cs.Requirements[0].Data, []byte{0, 0, 0, 1, 0, 0, 0, 3}) {
+		t.Errorf("requirements = %+v", cs.Requirements)
+	}
+
+	if cs.Entitlements["application-identifier"] != "TEAM123456.com.example.ex" {
+		t.Errorf("entitlements = %v", cs.Entitlements)
+	}
+
+	if cs.DEREntitlements["get-task-allow"] != true {
+		t.Errorf("DER entitlements = %v", cs.DEREntitlements)
+	}
+
+	if !cs.Signed {
+		t.Errorf("signed = false, want true")
+	}
+}
+
+func TestParseCodeSignatureAdhoc(t *testing.T) {
+	data := testSuperBlob(csMagicEmbeddedSignature,
+		testSlotBlob{Slot: csSlotCodeDirectory, Blob: testCodeDirectory("lib", "", 2, 1)},
+		testSlotBlob{Slot: csSlotSignature, Blob: testBlob(csMagicBlobWrapper, nil)})
+
+	cs, err := ParseCodeSignature(data)
+	if err != nil {
+		t.Fatalf("ParseCodeSignature() = %v", err)
+	}
+
+	if cs.Signed || cs.Entitlements != nil || cs.DEREntitlements != nil || cs.CodeDirectory().TeamID != "" {
+		t.Errorf("ParseCodeSignature() = %+v, want ad-hoc signature without entitlements", cs)
+	}
+
+	// Signatures need a CodeDirectory
+	_, err = ParseCodeSignature(testSuperBlob(csMagicEmbeddedSignature,
+		testSlotBlob{Slot: csSlotSignature, Blob: testBlob(csMagicBlobWrapper, nil)}))
+
+	if err == nil {
+		t.Errorf("ParseCodeSignature() without code directory succeeded, want error")
+	}
+}
+
+func TestReadCodeSignature(t *testing.T) {
+	signature := testSuperBlob(csMagicEmbeddedSignature,
+		testSlotBlob{Slot: csSlotEntitlements, Blob: testBlob(csMagicEmbeddedEntitlements, []byte("entitlements"))})
+
+	data := append(make([]byte, 0x100), signature...)
+	fsys := fstest.MapFS{"Ex": {Data: data}}
+
+	tests := []struct {
+		name    string // name is the name of the test.
+		offset  uint32 // offset is the offset of the code signature.
+		size    uint32 // size is the size of the code signature.
+		want    []byte // want is the expected code signature.
+		wantErr bool   // wantErr is true if reading is expected to fail.
+	}{
+		{name: "valid", offset: 0x100, size: uint32(len(signature)), want: signature},
+		{name: "unsigned", offset: 0, size: 0, want: nil},
+		{name: "beyond end of file", offset: 0x100, size: uint32(len(signature)) + 1, wantErr: true},
+		{name: "offset beyond end of file", offset: 0xFFFFFFF0, size: 0x100, wantErr: true},
+		{name: "too large", offset: 0x100, size: maxCodeSignatureSize + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &MachOInfo{Path: "Ex", CodeSigCommandOffset: 32, CodeSigOffset: tt.offset, CodeSigSize: tt.size}
+
+			got, err := readCodeSignature(fsys, info)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("readCodeSignature() = %x, want error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readCodeSignature() = %v", err)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("readCodeSignature() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package decrypt
+
+import (
+	"context"
+	"io"
+
+	"github.com/frida/frida-go/frida"
+)
+
+// contextReader wraps a reader and fails all reads once the context is done.
+type contextReader struct {
+	ctx context.Context // ctx is the context guarding the reader.
+	r   io.Reader       // r is the wrapped reader.
+}
+
+// Read reads from the wrapped reader, unless the context is done.
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return cr.r.Read(p)
+}
+
+// withCancellable returns a Frida option that cancels the operation once the context is done. The returned function
+// must be called after the operation finished to release resources.
+func withCancellable(ctx context.Context) (frida.OptFunc, func()) {
+	cancellable := frida.NewCancellable()
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancellable.Cancel()
+		case <-done:
+		}
+	}()
+
+	return frida.WithCancel(cancellable), func() {
+		close(done)
+		cancellable.Unref()
+	}
+}
@@ -0,0 +1,196 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDaemonTimeout is the time to wait for a daemon to start if DumpOptions.DaemonTimeout is not set.
+	defaultDaemonTimeout = 30 * time.Second
+
+	// daemonPollInterval is the interval the process list is polled with while waiting for a daemon to start.
+	daemonPollInterval = 500 * time.Millisecond
+
+	// guiUser is the user whose GUI domain per-user daemons run in.
+	guiUser = "mobile"
+)
+
+// daemon describes a system daemon required for dumping.
+type daemon struct {
+	Name   string // Name is the process name of the daemon.
+	Domain string // Domain is the launchd domain of the daemon ("system" or "gui").
+	Label  string // Label is the launchd label of the daemon.
+}
+
+// requiredDaemons are the daemons that need to be running for dumping.
+var requiredDaemons = []daemon{
+	{
+		Name:   "runningboardd",
+		Domain: "system",
+		Label:  "com.apple.runningboardd",
+	},
+	{
+		Name:   "chronod",
+		Domain: "gui",
+		Label:  "com.apple.chronod",
+	},
+}
+
+// service returns the launchd service target of the daemon. Targets in the GUI domain include the UID of guiUser.
+func (dmn daemon) service(uid string) string {
+	if dmn.Domain == "gui" {
+		return "gui/" + uid + "/" + dmn.Label
+	}
+
+	return dmn.Domain + "/" + dmn.Label
+}
+
+// sudoAuthFailures are parts of the messages sudo fails with if it isn't able to authenticate the user.
+var sudoAuthFailures = []string{
+	"incorrect password",
+	"Sorry, try again",
+	"a password is required",
+	"no password was provided",
+	"not in the sudoers file",
+}
+
+// sudoAuthFailed returns true if the output of sudo shows that it failed to authenticate the user.
+func sudoAuthFailed(out string) bool {
+	for _, failure := range sudoAuthFailures {
+		if strings.Contains(out, failure) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ensureDaemons makes sure all required daemons are running, starting them if necessary.
+func (d *Dumper) ensureDaemons(ctx context.Context) error {
+	for _, dmn := range requiredDaemons {
+		pid, err := d.ensureDaemon(ctx, dmn)
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Found daemon process ID", slog.String("name", dmn.Name), slog.Int("pid", pid))
+	}
+
+	return nil
+}
+
+// ensureDaemon returns the process ID of a daemon. If the daemon is not running, it is started via launchctl over
+// SSH, and waited for until the daemon timeout expires.
+func (d *Dumper) ensureDaemon(ctx context.Context, dmn daemon) (int, error) {
+	// Check if daemon is running already
+	pid, err := d.device.GetProcessID(ctx, dmn.Name)
+	if err == nil {
+		return pid, nil
+	}
+
+	slog.Warn("Daemon is not running, starting it", slog.String("name", dmn.Name))
+
+	// Start daemon
+	err = d.startDaemon(ctx, dmn)
+	if err != nil {
+		return 0, fmt.Errorf("start daemon [%s]: %w", dmn.Name, err)
+	}
+
+	// Wait for daemon to appear in the process list
+	timeout := d.opts.DaemonTimeout
+	if timeout <= 0 {
+		timeout = defaultDaemonTimeout
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(daemonPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pid, err := d.device.GetProcessID(waitCtx, dmn.Name)
+		if err == nil {
+			return pid, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+
+			return 0, fmt.Errorf("%w [%s]: still not running after %s", ErrProcessNotFound, dmn.Name, timeout)
+		}
+	}
+}
+
+// startDaemon starts a daemon via "launchctl kickstart" over SSH. launchd only lets root kickstart system services,
+// so launchctl is run via sudo, unless the dumper logs in as root. sudo is passed the SSH password.
+func (d *Dumper) startDaemon(ctx context.Context, dmn daemon) error {
+	// Connect to device
+	err := d.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Resolve UID of the GUI domain
+	var uid string
+
+	if dmn.Domain == "gui" {
+		out, err := d.runCommand("id -u "+shellQuote(guiUser), "")
+		if err != nil {
+			return fmt.Errorf("resolve UID of [%s]: %w [%s]", guiUser, err, out)
+		}
+
+		uid = out
+	}
+
+	// Run launchctl as root
+	user, password := d.opts.SSH.credentials()
+	command := "launchctl kickstart " + shellQuote(dmn.service(uid))
+
+	if user == "root" {
+		out, err := d.runCommand(command, "")
+		if err != nil {
+			return fmt.Errorf("run launchctl: %w [%s]", err, out)
+		}
+
+		return nil
+	}
+
+	out, err := d.runCommand("sudo -S -p '' "+command, password+"\n")
+	if err != nil {
+		if sudoAuthFailed(out) {
+			return fmt.Errorf("%w: sudo rejected the password of [%s], set the SSH password or log in as root [%s]", ErrSudoAuthentication, user, out)
+		}
+
+		return fmt.Errorf("run launchctl: %w [%s]", err, out)
+	}
+
+	return nil
+}
+
+// runCommand runs a command over the SSH connection of the dumper, passing stdin to it. It returns the combined and
+// trimmed output of the command.
+func (d *Dumper) runCommand(command string, stdin string) (string, error) {
+	// Open SSH session
+	session, err := d.ssh.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("open SSH session: %w", err)
+	}
+
+	defer session.Close()
+
+	// Run command
+	session.Stdin = strings.NewReader(stdin)
+
+	out, err := session.CombinedOutput(command)
+
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,69 @@
+package decrypt
+
+import (
+	"testing"
+)
+
+func TestDaemonService(t *testing.T) {
+	tests := []struct {
+		name string // name is the name of the test.
+		dmn  daemon // dmn is the daemon.
+		want string // want is the expected launchd service target.
+	}{
+		{name: "system domain", dmn: daemon{Name: "runningboardd", Domain: "system", Label: "com.apple.runningboardd"}, want: "system/com.apple.runningboardd"},
+		{name: "GUI domain", dmn: daemon{Name: "chronod", Domain: "gui", Label: "com.apple.chronod"}, want: "gui/502/com.apple.chronod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.dmn.service("502"); got != tt.want {
+				t.Errorf("service() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSudoAuthFailed(t *testing.T) {
+	tests := []struct {
+		name string // name is the name of the test.
+		out  string // out is the output of sudo.
+		want bool   // want is true if authentication is expected to have failed.
+	}{
+		{name: "success", out: "", want: false},
+		{name: "launchctl error", out: "Could not kickstart service \"com.apple.chronod\": 113: Could not find specified service", want: false},
+		{name: "wrong password", out: "Sorry, try again.\nsudo: 1 incorrect password attempt", want: true},
+		{name: "no password", out: "sudo: no password was provided", want: true},
+		{name: "password required", out: "sudo: a password is required", want: true},
+		{name: "not a sudoer", out: "mobile is not in the sudoers file.  This incident will be reported.", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sudoAuthFailed(tt.out); got != tt.want {
+				t.Errorf("sudoAuthFailed() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHOptionsCredentials(t *testing.T) {
+	tests := []struct {
+		name         string     // name is the name of the test.
+		opts         SSHOptions // opts are the SSH options.
+		wantUser     string     // wantUser is the expected user.
+		wantPassword string     // wantPassword is the expected password.
+	}{
+		{name: "defaults", opts: SSHOptions{}, wantUser: "mobile", wantPassword: "alpine"},
+		{name: "root", opts: SSHOptions{User: "root"}, wantUser: "root", wantPassword: "alpine"},
+		{name: "configured", opts: SSHOptions{User: "mobile", Password: "secret"}, wantUser: "mobile", wantPassword: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, password := tt.opts.credentials()
+			if user != tt.wantUser || password != tt.wantPassword {
+				t.Errorf("credentials() = %q, %q, want %q, %q", user, password, tt.wantUser, tt.wantPassword)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package decrypt
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -12,10 +13,11 @@ import (
 type Device struct {
 	device frida.DeviceInt
 
-	Access   string // Access can be "full" or "limited".
-	Platform string // Platform can be "darwin", "linux", etc..
-	Arch     string // Arch can be "arm64", "x86_64", etc..
-	OS       string // OS can be "ios", "android", etc..
+	Access    string // Access can be "full" or "limited".
+	Platform  string // Platform can be "darwin", "linux", etc..
+	Arch      string // Arch can be "arm64", "x86_64", etc..
+	OS        string // OS can be "ios", "android", etc..
+	OSVersion string // OSVersion can be "16.5", "17.0.3", etc..
 }
 
 var (
@@ -24,7 +26,7 @@ var (
 )
 
 // FindDevice returns the first available USB device that matches the criteria.
-func FindDevice() (*Device, error) {
+func FindDevice(ctx context.Context) (*Device, error) {
 	// Initialize device manager, if not done already
 	deviceManagerOnce.Do(func() {
 		deviceManager = frida.NewDeviceManager()
@@ -35,6 +37,10 @@ func FindDevice() (*Device, error) {
 	}
 
 	// Find proper device
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	devices, err := deviceManager.EnumerateDevices()
 	if err != nil {
 		return nil, fmt.Errorf("enumerate devices: %w", err)
@@ -59,11 +65,12 @@ func FindDevice() (*Device, error) {
 		Platform string `mapstructure:"platform"`
 		Arch     string `mapstructure:"arch"`
 		OS       struct {
-			ID string `mapstructure:"id"`
+			ID      string `mapstructure:"id"`
+			Version string `mapstructure:"version"`
 		} `mapstructure:"os"`
 	}
 
-	ps, err := device.Params()
+	ps, err := device.ParamsWithContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get device parameters: %w", err)
 	}
@@ -74,17 +81,37 @@ func FindDevice() (*Device, error) {
 	}
 
 	return &Device{
-		device:   device,
-		Access:   params.Access,
-		Platform: params.Platform,
-		Arch:     params.Arch,
-		OS:       params.OS.ID,
+		device:    device,
+		Access:    params.Access,
+		Platform:  params.Platform,
+		Arch:      params.Arch,
+		OS:        params.OS.ID,
+		OSVersion: params.OS.Version,
 	}, nil
 }
 
+// CheckRequirements returns an error wrapping ErrUnsupportedDevice unless the device is a 64-bit iOS device that
+// allows full access (i.e. is jailbroken).
+func (dev *Device) CheckRequirements() error {
+	if dev.Access != "full" {
+		return fmt.Errorf("%w: access is %q, full access required", ErrUnsupportedDevice, dev.Access)
+	}
+
+	if dev.Platform != "darwin" || dev.OS != "ios" || dev.Arch != "arm64" {
+		return fmt.Errorf("%w: platform is %s/%s/%s, darwin/ios/arm64 required", ErrUnsupportedDevice, dev.Platform,
+			dev.OS, dev.Arch)
+	}
+
+	return nil
+}
+
 // GetProcessID retrieves the process ID of a running application by its name.
-func (dev *Device) GetProcessID(name string) (int, error) {
+func (dev *Device) GetProcessID(ctx context.Context, name string) (int, error) {
 	// Enumerate processes
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	processes, err := dev.device.EnumerateProcesses(frida.ScopeMetadata)
 	if err != nil {
 		return 0, fmt.Errorf("enumerate processes: %w", err)
@@ -97,5 +124,5 @@ func (dev *Device) GetProcessID(name string) (int, error) {
 		}
 	}
 
-	return 0, fmt.Errorf("process not found [%s]", name)
+	return 0, fmt.Errorf("%w [%s]", ErrProcessNotFound, name)
 }
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package decrypt
+
+import (
+	"errors"
+)
+
+// freeDiskSpace is not supported on this platform.
+func freeDiskSpace(_ string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package decrypt
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// freeDiskSpace returns the disk space in bytes available to unprivileged users on the filesystem containing path.
+func freeDiskSpace(path string) (uint64, error) {
+	var stat unix.Statfs_t
+
+	err := unix.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
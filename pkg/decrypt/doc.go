@@ -0,0 +1,32 @@
+// Package decrypt dumps decrypted iOS applications from a jailbroken device via Frida.
+//
+// A typical use finds the USB device, lists its applications, and dumps the wanted ones into IPA files:
+//
+//	device, err := decrypt.FindDevice(ctx)
+//	if err != nil {
+//		return err
+//	}
+//
+//	if err := device.CheckRequirements(); err != nil {
+//		return err
+//	}
+//
+//	apps, err := device.ListApplications(ctx)
+//	if err != nil {
+//		return err
+//	}
+//
+//	dumper := device.NewDumper(decrypt.DumpOptions{OutputDir: "ipas"})
+//	defer dumper.Close()
+//
+//	for _, app := range apps {
+//		if app.IsUserApp() {
+//			res, err := dumper.Dump(ctx, app)
+//			...
+//		}
+//	}
+//
+// Progress is reported via the Progress interface, and log messages are written to the default slog logger. Errors
+// reported by the scripts running on the device can be told apart with errors.Is (e.g. ErrBundleNotFound), and
+// Diagnose checks all prerequisites for dumping.
+package decrypt
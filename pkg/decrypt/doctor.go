@@ -0,0 +1,240 @@
+package decrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/frida/frida-go/frida"
+)
+
+// defaultMinFreeSpace is the free local disk space required if DoctorOptions.MinFreeSpace is not set.
+const defaultMinFreeSpace = 2 << 30
+
+// DoctorOptions configures which prerequisites are checked by Diagnose.
+type DoctorOptions struct {
+	OutputDir    string     // OutputDir is the local directory whose free disk space is checked ("." if empty).
+	MinFreeSpace uint64     // MinFreeSpace is the free disk space required in OutputDir (2 GiB if zero).
+	SSH          SSHOptions // SSH configures the SSH connection to the device.
+}
+
+// Check is the result of checking a single prerequisite.
+type Check struct {
+	Name   string // Name is the name of the prerequisite.
+	Detail string // Detail describes what was found.
+	Err    error  // Err is the reason the check failed (nil if it passed).
+	Hint   string // Hint describes how to fix a failed check.
+}
+
+// Passed returns true if the check has passed.
+func (c Check) Passed() bool {
+	return c.Err == nil
+}
+
+// errNoDevice is reported by checks that are skipped because no device was found.
+var errNoDevice = errors.New("skipped, no device found")
+
+// Diagnose checks all prerequisites for dumping applications, and returns one result per prerequisite. A failed check
+// does not stop the remaining checks, unless they depend on it.
+func Diagnose(ctx context.Context, opts DoctorOptions) []Check {
+	var checks []Check
+
+	// USB device
+	dev, err := FindDevice(ctx)
+
+	checks = append(checks, Check{
+		Name: "USB device",
+		Err:  err,
+		Hint: "Connect the device via USB, unlock it, and make sure frida-server is running on it.",
+	})
+
+	if dev != nil {
+		checks = append(checks, dev.diagnose(ctx)...)
+	} else {
+		for _, name := range []string{"Access", "Platform", "Frida version"} {
+			checks = append(checks, Check{Name: name, Err: errNoDevice})
+		}
+
+		for _, dmn := range requiredDaemons {
+			checks = append(checks, Check{Name: dmn.Name, Err: errNoDevice})
+		}
+	}
+
+	// SSH and SFTP
+	d := &Dumper{opts: DumpOptions{SSH: opts.SSH}}
+	defer d.Close()
+
+	err = d.connect(ctx)
+	user, _ := opts.SSH.credentials()
+
+	checks = append(checks, Check{
+		Name:   "SSH",
+		Detail: user + "@localhost:2222",
+		Err:    err,
+		Hint:   "Forward port 2222 to the SSH port of the device (e.g. \"iproxy 2222 22\"), and check the credentials.",
+	})
+
+	sftpCheck := Check{
+		Name: "SFTP",
+		Hint: "Install an SFTP server on the device, or use \"--transfer tar\" or \"--transfer frida\".",
+	}
+
+	switch {
+	case err != nil:
+		sftpCheck.Err = errors.New("skipped, no SSH connection")
+	case d.sftp == nil:
+		sftpCheck.Err = errors.New("SFTP subsystem unavailable")
+	}
+
+	checks = append(checks, sftpCheck)
+
+	// Local disk space
+	dir := opts.OutputDir
+	if dir == "" {
+		dir = "."
+	}
+
+	minFree := opts.MinFreeSpace
+	if minFree == 0 {
+		minFree = defaultMinFreeSpace
+	}
+
+	diskCheck := Check{
+		Name: "Disk space",
+		Hint: fmt.Sprintf("Free up at least %s in %q, or choose another output directory.", formatSize(minFree), dir),
+	}
+
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		diskCheck.Err = fmt.Errorf("get free disk space: %w", err)
+	} else {
+		diskCheck.Detail = fmt.Sprintf("%s free in %q", formatSize(free), dir)
+
+		if free < minFree {
+			diskCheck.Err = fmt.Errorf("less than %s free", formatSize(minFree))
+		}
+	}
+
+	checks = append(checks, diskCheck)
+
+	return checks
+}
+
+// diagnose checks the prerequisites regarding the device itself.
+func (dev *Device) diagnose(ctx context.Context) []Check {
+	var checks []Check
+
+	// Access
+	accessCheck := Check{
+		Name:   "Access",
+		Detail: dev.Access,
+		Hint:   "Run frida-server as root on a jailbroken device.",
+	}
+
+	if dev.Access != "full" {
+		accessCheck.Err = errors.New("full access required")
+	}
+
+	checks = append(checks, accessCheck)
+
+	// Platform
+	platformCheck := Check{
+		Name:   "Platform",
+		Detail: fmt.Sprintf("%s/%s/%s", dev.Platform, dev.OS, dev.Arch),
+		Hint:   "Use a 64-bit iOS device.",
+	}
+
+	if dev.Platform != "darwin" || dev.OS != "ios" || dev.Arch != "arm64" {
+		platformCheck.Err = errors.New("darwin/ios/arm64 required")
+	}
+
+	checks = append(checks, platformCheck)
+
+	// Frida version
+	checks = append(checks, dev.diagnoseFridaVersion(ctx))
+
+	// Daemons
+	for _, dmn := range requiredDaemons {
+		daemonCheck := Check{
+			Name: dmn.Name,
+			Hint: fmt.Sprintf("Run \"sudo launchctl kickstart %s\" on the device (decrypt tries this automatically).", dmn.service("$(id -u "+guiUser+")")),
+		}
+
+		pid, err := dev.GetProcessID(ctx, dmn.Name)
+		if err != nil {
+			daemonCheck.Err = err
+		} else {
+			daemonCheck.Detail = fmt.Sprintf("PID %d", pid)
+		}
+
+		checks = append(checks, daemonCheck)
+	}
+
+	return checks
+}
+
+// diagnoseFridaVersion checks that the major version of frida-server matches the one of the local Frida library.
+func (dev *Device) diagnoseFridaVersion(ctx context.Context) Check {
+	local := frida.Version()
+
+	check := Check{
+		Name: "Frida version",
+		Hint: fmt.Sprintf("Install frida-server %s on the device.", local),
+	}
+
+	// Get version of frida-server from a script running in the system session
+	remote, err := dev.fridaVersion(ctx)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("server %s, client %s", remote, local)
+
+	if majorVersion(remote) != majorVersion(local) {
+		check.Err = errors.New("major versions differ")
+	}
+
+	return check
+}
+
+// fridaVersion returns the version of frida-server running on the device.
+func (dev *Device) fridaVersion(ctx context.Context) (string, error) {
+	content, err := readScript(preludeScript)
+	if err != nil {
+		return "", fmt.Errorf("read script [%s]: %w", preludeScript, err)
+	}
+
+	script, err := dev.LoadScriptIntoPID(ctx, preludeScript, content, 0)
+	if err != nil {
+		return "", fmt.Errorf("load script into system session: %w", err)
+	}
+
+	defer script.Close()
+
+	return CallAs[string](ctx, script, "version")
+}
+
+// majorVersion returns the major version of a "<major>.<minor>.<patch>" version string.
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+// formatSize formats a size in bytes with a binary unit (e.g. "1.5 GiB").
+func formatSize(size uint64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,390 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// DumpOptions configures how an application is dumped.
+type DumpOptions struct {
+	Transfer       Transfer      // Transfer is the strategy used to pull the app bundle from the device.
+	WorkDir        string        // WorkDir is the directory app bundles are pulled into (unique temporary ones if empty).
+	Output         string        // Output is the path of the resulting IPA (see OutputDir if empty).
+	OutputDir      string        // OutputDir is the directory for "<identifier>_<version>_<build>.ipa" if Output is empty.
+	KeepWorkDir    bool          // KeepWorkDir keeps the work directory after dumping.
+	Progress       Progress      // Progress receives progress updates (none are reported if nil).
+	Cache          *Cache        // Cache records produced IPAs, and is used to skip already dumped ones (if not nil).
+	Force          bool          // Force dumps applications even if they are already in the cache.
+	DaemonTimeout  time.Duration // DaemonTimeout is the time to wait for a required daemon to start (30s if zero).
+	Cleanup        *CleanupRules // Cleanup defines what is removed from the app bundle (DefaultCleanupRules if nil).
+	NoCleanup      bool          // NoCleanup keeps everything in the app bundle, ignoring Cleanup.
+	SigningReport  bool          // SigningReport writes the original signing artifacts to "<output>.signing.json".
+	StripSignature bool          // StripSignature removes the (no longer valid) code signature from decrypted binaries.
+	AdhocSign      bool          // AdhocSign re-signs all unencrypted binaries ad-hoc, replacing their code signatures.
+	Sign           SignOptions   // Sign configures ad-hoc signing.
+	NoScan         bool          // NoScan skips scanning the app bundle on the device before pulling it.
+	SSH            SSHOptions    // SSH configures the SSH connection to the device.
+}
+
+// Validate returns an error if options are combined that exclude each other.
+func (opts DumpOptions) Validate() error {
+	if opts.AdhocSign && opts.StripSignature {
+		return fmt.Errorf("ad-hoc signing and stripping code signatures exclude each other")
+	}
+
+	return nil
+}
+
+// Dumper dumps applications from a device. The SSH connection and the scripts loaded into device processes are
+// shared between dumps, so dumping several applications with the same dumper avoids setting them up repeatedly.
+type Dumper struct {
+	device *Device      // device is the device the applications are installed on.
+	opts   DumpOptions  // opts configures all dumps.
+	ssh    *ssh.Client  // ssh is the SSH connection, established on first use.
+	sftp   *sftp.Client // sftp is the SFTP connection, established on first use.
+
+	scripts map[string]*Script // scripts are the scripts loaded into runningboardd, by name.
+}
+
+// NewDumper creates a dumper for applications installed on the device.
+func (dev *Device) NewDumper(opts DumpOptions) *Dumper {
+	if opts.Progress == nil {
+		opts.Progress = nopProgress{}
+	}
+
+	return &Dumper{device: dev, opts: opts, scripts: make(map[string]*Script)}
+}
+
+// Close closes all connections and unloads all scripts of the dumper.
+func (d *Dumper) Close() {
+	for _, script := range d.scripts {
+		script.Close()
+	}
+
+	if d.sftp != nil {
+		d.sftp.Close() //nolint:errcheck
+	}
+
+	if d.ssh != nil {
+		d.ssh.Close() //nolint:errcheck
+	}
+}
+
+// Dump dumps the application into an IPA file. It's a shorthand for dumping a single application with a new dumper.
+func (app *Application) Dump(ctx context.Context, opts DumpOptions) (*DumpResult, error) {
+	d := app.device.NewDumper(opts)
+	defer d.Close()
+
+	return d.Dump(ctx, app)
+}
+
+// Dump dumps the application into an IPA file. Dumping is aborted as soon as the context is done. If the same
+// version and build of the application is already in the cache, dumping is skipped and an error wrapping ErrCached
+// is returned.
+//
+// The returned result describes the binaries, the IPA, timings and warnings. It's returned even if dumping fails,
+// covering everything done until then.
+//
+// The application's work directory is removed after dumping, unless KeepWorkDir is set. In an explicitly specified
+// work directory, every application gets its own subdirectory, which is kept if dumping fails, so an interrupted
+// transfer can be resumed by the next run. Only TransferSFTP and TransferFrida resume transfers, TransferTar always
+// pulls the whole bundle again. Temporary work directories are removed even if dumping fails, so nothing is resumed
+// without an explicit WorkDir.
+func (d *Dumper) Dump(ctx context.Context, app *Application) (res *DumpResult, err error) {
+	res = newDumpResult(app)
+
+	defer func() {
+		res.Timings.Total = time.Since(res.Started)
+	}()
+
+	// Check options
+	err = d.opts.Validate()
+	if err != nil {
+		return res, fmt.Errorf("invalid options: %w", err)
+	}
+
+	// Skip if already dumped
+	if entry, ok := d.cached(app); ok {
+		res.Output = entry.Path
+		return res, fmt.Errorf("%w [%s]", ErrCached, entry.Path)
+	}
+
+	// Prepare work directory
+	var workDir string
+
+	if d.opts.WorkDir != "" {
+		workDir = filepath.Join(d.opts.WorkDir, app.Identifier)
+	} else {
+		workDir, err = os.MkdirTemp("", "decrypt-")
+		if err != nil {
+			return res, fmt.Errorf("create work directory: %w", err)
+		}
+	}
+
+	defer func() {
+		if d.opts.KeepWorkDir || (d.opts.WorkDir != "" && err != nil) {
+			slog.Info("Keeping work directory", slog.String("path", workDir))
+			return
+		}
+
+		if err := os.RemoveAll(workDir); err != nil {
+			res.warn("Failed to remove work directory", slog.String("path", workDir), slog.Any("error", err))
+		}
+	}()
+
+	bundleDir := filepath.Join(workDir, "Payload", path.Base(app.Path))
+	progress := d.opts.Progress
+
+	// Make sure chronod and runningboardd are running, before spending time on pulling the app bundle
+	err = d.ensureDaemons(ctx)
+	if err != nil {
+		return res, fmt.Errorf("ensure daemons: %w", err)
+	}
+
+	// Scan the app bundle on the device
+	scan := d.scanBeforePull(ctx, app)
+
+	// Pull the app bundle to the local filesystem
+	start := time.Now()
+
+	err = d.pullBundle(ctx, app, bundleDir, scan)
+	res.Timings.Pull = time.Since(start)
+
+	if err != nil {
+		return res, fmt.Errorf("pull app directory: %w", err)
+	}
+
+	bundle := DirFS(bundleDir)
+
+	rules := DefaultCleanupRules()
+	if d.opts.Cleanup != nil {
+		rules = *d.opts.Cleanup
+	}
+
+	if d.opts.NoCleanup {
+		rules = CleanupRules{}
+	}
+
+	// Collect signing artifacts before they are cleaned up
+	var signing *SigningReport
+
+	if d.opts.SigningReport {
+		signing, err = buildSigningReport(bundle, rules)
+		if err != nil {
+			return res, fmt.Errorf("build signing report: %w", err)
+		}
+	}
+
+	// Clean up app bundle
+	err = cleanupAppBundle(bundle, rules)
+	if err != nil {
+		return res, fmt.Errorf("clean up app bundle: %w", err)
+	}
+
+	// Collect binaries from the app bundle
+	binaries, err := collectBinaries(bundle)
+	if err != nil {
+		return res, fmt.Errorf("collect binaries: %w", err)
+	}
+
+	for _, binary := range binaries {
+		slog.Info("Collected binary", slog.Any("binary", binary))
+	}
+
+	// Load script into runningboardd process
+	runningboardScript, err := d.script(ctx, "runningboardd.js")
+	if err != nil {
+		return res, fmt.Errorf("load script into process: %w", err)
+	}
+
+	// Get main
+	mainApp, err := CallAs[string](ctx, runningboardScript, "main", app.Identifier)
+	if err != nil {
+		return res, fmt.Errorf("get main app path: %w", err)
+	}
+
+	// Get extensions
+	extensions, err := CallAs[[]Extension](ctx, runningboardScript, "extensions", app.Identifier)
+	if err != nil {
+		return res, fmt.Errorf("get extension paths: %w", err)
+	}
+
+	// Split binaries into main and extensions
+	appBinaries, extensionBinaries := splitBinaries(binaries, mainApp, extensions)
+
+	// Leave binaries encrypted that can't be loaded on the device
+	for binaryPath, info := range appBinaries {
+		if info.SupportedOn(app.device.OSVersion) {
+			continue
+		}
+
+		res.warn("Binary requires a newer OS version than the device runs, and is left encrypted",
+			slog.String("path", binaryPath),
+			slog.String("minOS", info.MinOS),
+			slog.String("osVersion", app.device.OSVersion))
+
+		res.Binaries = append(res.Binaries, BinaryResult{
+			Path:          info.Path,
+			FileType:      info.FileTypeName(),
+			Slice:         info.SliceName(),
+			CryptIDBefore: info.CryptID,
+			CryptIDAfter:  info.CryptID,
+		})
+
+		delete(appBinaries, binaryPath)
+	}
+
+	slog.Info("Found main app binaries", slog.Any("binaries", appBinaries))
+	slog.Info("Found extension binaries", slog.Any("binaries", extensionBinaries))
+
+	// Decrypt main app binaries
+	start = time.Now()
+
+	binaryResults, err := app.dumpBinaries(ctx, bundleDir, appBinaries, progress)
+	res.Timings.Decrypt = time.Since(start)
+	res.Binaries = append(res.Binaries, binaryResults...)
+
+	if err != nil {
+		return res, fmt.Errorf("dump app binaries: %w", err)
+	}
+
+	for id, binaries := range extensionBinaries {
+		for binaryPath, info := range binaries {
+			res.warn("Extension binary is left encrypted", slog.String("extension", id), slog.String("path", binaryPath))
+
+			res.Binaries = append(res.Binaries, BinaryResult{
+				Path:          info.Path,
+				FileType:      info.FileTypeName(),
+				Slice:         info.SliceName(),
+				Extension:     id,
+				CryptIDBefore: info.CryptID,
+				CryptIDAfter:  info.CryptID,
+			})
+		}
+	}
+
+	// Re-sign all unencrypted binaries, or strip code signatures from decrypted ones
+	if d.opts.AdhocSign {
+		cdhashes, err := AdhocSignBundle(bundleDir, d.opts.Sign)
+		if err != nil {
+			return res, fmt.Errorf("ad-hoc sign app bundle: %w", err)
+		}
+
+		for i := range res.Binaries {
+			res.Binaries[i].CDHash = cdhashes[res.Binaries[i].Path]
+		}
+	} else if d.opts.StripSignature {
+		for i := range res.Binaries {
+			binary := &res.Binaries[i]
+
+			if !binary.Decrypted() {
+				continue
+			}
+
+			stripped, err := StripCodeSignature(filepath.Join(bundleDir, filepath.FromSlash(binary.Path)))
+			if err != nil {
+				return res, fmt.Errorf("strip code signature [%s]: %w", binary.Path, err)
+			}
+
+			binary.SignatureStripped = stripped
+		}
+	}
+
+	slices.SortFunc(res.Binaries, func(a, b BinaryResult) int { return strings.Compare(a.Path, b.Path) })
+
+	// Package IPA
+	output := d.opts.Output
+	if output == "" {
+		output = filepath.Join(d.opts.OutputDir, outputName(app))
+	}
+
+	progress.Start("Packaging IPA", 0)
+	start = time.Now()
+
+	err = writeIPA(ctx, bundle, path.Base(app.Path), output)
+	res.Timings.Package = time.Since(start)
+	progress.Stop()
+
+	if err != nil {
+		os.Remove(output) //nolint:errcheck
+		return res, fmt.Errorf("package IPA: %w", err)
+	}
+
+	res.Output = output
+
+	slog.Info("Dumped application", slog.String("output", output))
+
+	// Write signing report next to the IPA
+	if signing != nil {
+		reportPath := strings.TrimSuffix(output, filepath.Ext(output)) + ".signing.json"
+
+		err = writeSigningReport(signing, reportPath)
+		if err != nil {
+			return res, fmt.Errorf("write signing report: %w", err)
+		}
+
+		res.SigningReport = reportPath
+	}
+
+	// Record IPA in cache
+	if d.opts.Cache != nil {
+		if err := d.opts.Cache.Add(app, output); err != nil {
+			res.warn("Failed to add IPA to cache", slog.String("path", output), slog.Any("error", err))
+		}
+	}
+
+	return res, nil
+}
+
+// cached returns the cache entry of an already dumped application. Nothing is returned without a cache, or if dumping
+// is forced.
+func (d *Dumper) cached(app *Application) (CacheEntry, bool) {
+	if d.opts.Cache == nil || d.opts.Force {
+		return CacheEntry{}, false
+	}
+
+	return d.opts.Cache.Lookup(app)
+}
+
+// outputName returns the default file name of the IPA of an application. It includes the build number, so different
+// builds of the same version (which are cached separately) don't overwrite each other.
+func outputName(app *Application) string {
+	if app.Build == "" {
+		return fmt.Sprintf("%s_%s.ipa", app.Identifier, app.Version)
+	}
+
+	return fmt.Sprintf("%s_%s_%s.ipa", app.Identifier, app.Version, app.Build)
+}
+
+// script returns the script with the given name loaded into runningboardd, loading it on first use.
+func (d *Dumper) script(ctx context.Context, name string) (*Script, error) {
+	// Reuse loaded script
+	if script, ok := d.scripts[name]; ok {
+		return script, nil
+	}
+
+	// Load script
+	content, err := readScript(name)
+	if err != nil {
+		return nil, fmt.Errorf("read script [%s]: %w", name, err)
+	}
+
+	script, err := d.device.LoadScriptIntoProcess(ctx, name, content, "runningboardd")
+	if err != nil {
+		return nil, err
+	}
+
+	d.scripts[name] = script
+
+	return script, nil
+}
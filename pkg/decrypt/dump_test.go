@@ -0,0 +1,27 @@
+package decrypt
+
+import (
+	"testing"
+)
+
+func TestDumpOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string      // name is the name of the test.
+		opts    DumpOptions // opts are the validated options.
+		wantErr bool        // wantErr is true if validation is expected to fail.
+	}{
+		{name: "defaults", opts: DumpOptions{}, wantErr: false},
+		{name: "ad-hoc signing", opts: DumpOptions{AdhocSign: true, Sign: SignOptions{KeepEntitlements: true}}, wantErr: false},
+		{name: "stripping signatures", opts: DumpOptions{StripSignature: true}, wantErr: false},
+		{name: "ad-hoc signing and stripping signatures", opts: DumpOptions{AdhocSign: true, StripSignature: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package decrypt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrBundleNotFound is reported by scripts if no application with the bundle identifier is installed.
+	ErrBundleNotFound = errors.New("bundle not found")
+
+	// ErrObjCUnavailable is reported by scripts if the Objective-C runtime is not available in the process.
+	ErrObjCUnavailable = errors.New("Objective-C runtime unavailable")
+
+	// ErrFileNotFound is reported by scripts if a file or directory does not exist on the device.
+	ErrFileNotFound = errors.New("file not found")
+
+	// ErrModuleNotLoaded is reported by scripts if a binary could not be loaded into the process.
+	ErrModuleNotLoaded = errors.New("module not loaded")
+
+	// ErrUnsupportedDevice is returned if a device is not a jailbroken 64-bit iOS device.
+	ErrUnsupportedDevice = errors.New("unsupported device")
+
+	// ErrProcessNotFound is returned if a process is not running on the device.
+	ErrProcessNotFound = errors.New("process not found")
+
+	// ErrSudoAuthentication is returned if sudo on the device fails to authenticate the SSH user.
+	ErrSudoAuthentication = errors.New("sudo authentication failed")
+)
+
+// scriptErrorCodes maps error codes reported by scripts to sentinel errors.
+var scriptErrorCodes = map[string]error{
+	"bundle-not-found":  ErrBundleNotFound,
+	"objc-unavailable":  ErrObjCUnavailable,
+	"file-not-found":    ErrFileNotFound,
+	"module-not-loaded": ErrModuleNotLoaded,
+}
+
+// ScriptError is an error reported by a script. It matches the sentinel error of its code with errors.Is.
+type ScriptError struct {
+	Function string `mapstructure:"-"`       // Function is the name of the called export.
+	Code     string `mapstructure:"code"`    // Code is the machine-readable error code (e.g. "bundle-not-found").
+	Message  string `mapstructure:"message"` // Message is the human-readable error message.
+	Stack    string `mapstructure:"stack"`   // Stack is the JavaScript stack trace, if available.
+}
+
+// Error returns the error message.
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("script call [%s]: %s [%s]", e.Function, e.Message, e.Code)
+}
+
+// Format formats the error. The "%+v" verb includes the JavaScript stack trace.
+func (e *ScriptError) Format(f fmt.State, verb rune) {
+	io.WriteString(f, e.Error()) //nolint:errcheck
+
+	if verb == 'v' && f.Flag('+') && e.Stack != "" {
+		io.WriteString(f, "\n"+e.Stack) //nolint:errcheck
+	}
+}
+
+// Is returns true if target is the sentinel error of the error's code.
+func (e *ScriptError) Is(target error) bool {
+	sentinel, ok := scriptErrorCodes[e.Code]
+	return ok && sentinel == target
+}
@@ -0,0 +1,399 @@
+package decrypt
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+)
+
+// WritableFS is a filesystem that files and directories can be removed from. Names are slash-separated and relative
+// to the root of the filesystem, as with fs.FS.
+type WritableFS interface {
+	fs.FS
+
+	// Remove removes a file or an empty directory.
+	Remove(name string) error
+
+	// RemoveAll removes a file or a directory and everything it contains. It returns nil if name doesn't exist.
+	RemoveAll(name string) error
+}
+
+var (
+	// errDirNotEmpty is returned when removing a directory that is not empty.
+	errDirNotEmpty = errors.New("directory not empty")
+
+	// errRangeOutOfFile is returned when reading a range that exceeds the file.
+	errRangeOutOfFile = errors.New("range exceeds file")
+)
+
+// dirFS is a writable filesystem rooted at a local directory.
+type dirFS struct {
+	fs.FS        // FS reads from the directory.
+	dir   string // dir is the local directory.
+}
+
+// DirFS returns a writable filesystem for the tree of files rooted at the local directory dir.
+func DirFS(dir string) WritableFS {
+	return &dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+// Remove removes a file or an empty directory.
+func (d *dirFS) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return os.Remove(filepath.Join(d.dir, filepath.FromSlash(name)))
+}
+
+// RemoveAll removes a file or a directory and everything it contains.
+func (d *dirFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return os.RemoveAll(filepath.Join(d.dir, filepath.FromSlash(name)))
+}
+
+// maskFS is a writable filesystem on top of a read-only one. Removed files and directories are only hidden.
+type maskFS struct {
+	fsys    fs.FS           // fsys is the underlying filesystem.
+	mu      sync.RWMutex    // mu guards removed.
+	removed map[string]bool // removed are the hidden files and directories.
+}
+
+// MaskFS returns a writable filesystem on top of the read-only filesystem fsys. Removing files and directories hides
+// them, leaving fsys unchanged. This allows processing e.g. an opened IPA file or an fstest.MapFS like a directory.
+func MaskFS(fsys fs.FS) WritableFS {
+	return &maskFS{fsys: fsys, removed: make(map[string]bool)}
+}
+
+// hidden returns true if the file or one of its parent directories has been removed.
+func (m *maskFS) hidden(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for p := name; p != "."; p = path.Dir(p) {
+		if m.removed[p] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Open opens a file that has not been removed.
+func (m *maskFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if m.hidden(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	file, err := m.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir, ok := file.(fs.ReadDirFile); ok {
+		return &maskDir{ReadDirFile: dir, fs: m, name: name}, nil
+	}
+
+	return file, nil
+}
+
+// ReadDir reads a directory, omitting removed entries.
+func (m *maskFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if m.hidden(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries, err := fs.ReadDir(m.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.filter(name, entries), nil
+}
+
+// filter omits removed entries of a directory.
+func (m *maskFS) filter(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	return slices.DeleteFunc(entries, func(e fs.DirEntry) bool {
+		return m.hidden(path.Join(dir, e.Name()))
+	})
+}
+
+// Remove hides a file or an empty directory.
+func (m *maskFS) Remove(name string) error {
+	info, err := fs.Stat(m, name)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		entries, err := m.ReadDir(name)
+		if err != nil {
+			return err
+		}
+
+		if len(entries) > 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: errDirNotEmpty}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removed[name] = true
+
+	return nil
+}
+
+// RemoveAll hides a file or a directory and everything it contains.
+func (m *maskFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removed[name] = true
+
+	return nil
+}
+
+// maskDir is an opened directory of a maskFS.
+type maskDir struct {
+	fs.ReadDirFile         // ReadDirFile is the underlying directory.
+	fs             *maskFS // fs is the filesystem the directory belongs to.
+	name           string  // name is the name of the directory.
+}
+
+// ReadDir reads directory entries, omitting removed ones.
+func (d *maskDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		entries, err := d.ReadDirFile.ReadDir(n)
+
+		entries = d.fs.filter(d.name, entries)
+		if len(entries) > 0 || err != nil || n <= 0 {
+			return entries, err
+		}
+	}
+}
+
+// sftpFS is a writable filesystem rooted at a remote directory, accessed via SFTP.
+type sftpFS struct {
+	client *sftp.Client // client is the SFTP connection.
+	root   string       // root is the remote directory.
+}
+
+// SFTPFS returns a writable filesystem for the tree of files rooted at the remote directory root.
+func SFTPFS(client *sftp.Client, root string) WritableFS {
+	return &sftpFS{client: client, root: root}
+}
+
+// remotePath returns the remote path of a file.
+func (s *sftpFS) remotePath(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return path.Join(s.root, name), nil
+}
+
+// Open opens a remote file or directory.
+func (s *sftpFS) Open(name string) (fs.File, error) {
+	remotePath, err := s.remotePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.client.Stat(remotePath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	// Directories can't be opened via SFTP, so they are listed on demand instead
+	if info.IsDir() {
+		return &sftpDir{fs: s, name: name, info: info}, nil
+	}
+
+	file, err := s.client.Open(remotePath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return file, nil
+}
+
+// Stat returns information about a remote file.
+func (s *sftpFS) Stat(name string) (fs.FileInfo, error) {
+	remotePath, err := s.remotePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Stat(remotePath)
+}
+
+// ReadDir lists a remote directory, sorted by name.
+func (s *sftpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	remotePath, err := s.remotePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := s.client.ReadDir(remotePath)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(infos))
+
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+
+	return entries, nil
+}
+
+// Remove removes a remote file or an empty directory.
+func (s *sftpFS) Remove(name string) error {
+	remotePath, err := s.remotePath("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Remove(remotePath)
+}
+
+// RemoveAll removes a remote file or a directory and everything it contains.
+func (s *sftpFS) RemoveAll(name string) error {
+	remotePath, err := s.remotePath("removeall", name)
+	if err != nil {
+		return err
+	}
+
+	if name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+
+	err = s.client.RemoveAll(remotePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// sftpDir is an opened remote directory.
+type sftpDir struct {
+	fs      *sftpFS       // fs is the filesystem the directory belongs to.
+	name    string        // name is the name of the directory.
+	info    fs.FileInfo   // info describes the directory.
+	entries []fs.DirEntry // entries are the remaining entries, listed on first read.
+	listed  bool          // listed is true if the entries have been listed.
+}
+
+// Stat returns information about the directory.
+func (d *sftpDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+// Read fails, as directories can't be read.
+func (d *sftpDir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// Close closes the directory.
+func (d *sftpDir) Close() error {
+	return nil
+}
+
+// ReadDir reads up to n directory entries (all remaining ones if n <= 0).
+func (d *sftpDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries, d.listed = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+// readFileRange reads size bytes at offset off from a file in fsys. Files that are not seekable (e.g. compressed
+// archive entries) are read up to the range. The range must be within the file.
+func readFileRange(fsys fs.FS, name string, off int64, size int) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	// Check range before allocating it
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if off < 0 || size < 0 || off+int64(size) > info.Size() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errRangeOutOfFile}
+	}
+
+	buf := make([]byte, size)
+
+	// Read range directly if possible
+	if ra, ok := file.(io.ReaderAt); ok {
+		_, err = ra.ReadAt(buf, off)
+		if err != nil {
+			return nil, err
+		}
+
+		return buf, nil
+	}
+
+	// Skip to the range otherwise
+	_, err = io.CopyN(io.Discard, file, off)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.ReadFull(file, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
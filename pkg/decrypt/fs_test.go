@@ -0,0 +1,254 @@
+package decrypt
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// testBundleFS returns a small app bundle as an in-memory filesystem.
+func testBundleFS() fstest.MapFS {
+	return fstest.MapFS{
+		"Info.plist":                          {Data: []byte("info")},
+		"Ex":                                  {Data: []byte("executable")},
+		"Empty":                               {Mode: fs.ModeDir},
+		"Frameworks/A.framework/A":            {Data: []byte("framework")},
+		"Frameworks/A.framework/Info.plist":   {Data: []byte("info")},
+		"Frameworks/B.dylib":                  {Data: []byte("dylib")},
+		"_CodeSignature/CodeResources":        {Data: []byte("resources")},
+		"en.lproj/Localizable.strings":        {Data: []byte("strings")},
+		"en.lproj/InfoPlist.strings":          {Data: []byte("strings")},
+		"PlugIns/W.appex/W":                   {Data: []byte("extension")},
+		"PlugIns/W.appex/_CodeSignature/Seal": {Data: []byte("seal")},
+	}
+}
+
+func TestMaskFSHidden(t *testing.T) {
+	tests := []struct {
+		name    string   // name is the name of the test.
+		remove  []string // remove are the paths removed via RemoveAll.
+		open    string   // open is the path opened.
+		visible bool     // visible is true if the path is expected to exist.
+	}{
+		{name: "untouched file", remove: nil, open: "Ex", visible: true},
+		{name: "removed file", remove: []string{"Ex"}, open: "Ex", visible: false},
+		{name: "removed directory", remove: []string{"Frameworks"}, open: "Frameworks", visible: false},
+		{name: "file in removed directory", remove: []string{"Frameworks"}, open: "Frameworks/A.framework/A", visible: false},
+		{name: "sibling of removed file", remove: []string{"Frameworks/B.dylib"}, open: "Frameworks/A.framework/A", visible: true},
+		{name: "prefix of removed name", remove: []string{"Frameworks/A.framework/Info"}, open: "Frameworks/A.framework/Info.plist", visible: true},
+		{name: "root", remove: []string{"Ex", "Frameworks"}, open: ".", visible: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := MaskFS(testBundleFS())
+
+			for _, name := range tt.remove {
+				if err := fsys.RemoveAll(name); err != nil {
+					t.Fatalf("RemoveAll(%q) = %v", name, err)
+				}
+			}
+
+			file, err := fsys.Open(tt.open)
+			if err == nil {
+				file.Close()
+			}
+
+			if tt.visible && err != nil {
+				t.Errorf("Open(%q) = %v, want visible", tt.open, err)
+			}
+
+			if !tt.visible && !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Open(%q) = %v, want %v", tt.open, err, fs.ErrNotExist)
+			}
+
+			_, err = fs.Stat(fsys, tt.open)
+			if tt.visible != (err == nil) {
+				t.Errorf("Stat(%q) = %v, want visible %t", tt.open, err, tt.visible)
+			}
+		})
+	}
+}
+
+func TestMaskFSInvalidPath(t *testing.T) {
+	fsys := MaskFS(testBundleFS())
+
+	for _, name := range []string{"/Ex", "../Ex", "Frameworks/"} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q) = %v, want %v", name, err, fs.ErrInvalid)
+		}
+	}
+
+	for _, name := range []string{".", "/Ex", "../Ex"} {
+		if err := fsys.RemoveAll(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("RemoveAll(%q) = %v, want %v", name, err, fs.ErrInvalid)
+		}
+	}
+}
+
+func TestMaskDirReadDir(t *testing.T) {
+	tests := []struct {
+		name   string   // name is the name of the test.
+		remove []string // remove are the paths removed via RemoveAll.
+		dir    string   // dir is the directory read.
+		n      int      // n is the number of entries read per call.
+		want   []string // want are the expected entry names.
+	}{
+		{name: "all entries", remove: nil, dir: "en.lproj", n: -1, want: []string{"InfoPlist.strings", "Localizable.strings"}},
+		{name: "removed entry", remove: []string{"en.lproj/InfoPlist.strings"}, dir: "en.lproj", n: -1, want: []string{"Localizable.strings"}},
+		{name: "removed entry in batches", remove: []string{"en.lproj/InfoPlist.strings"}, dir: "en.lproj", n: 1, want: []string{"Localizable.strings"}},
+		{name: "all entries removed", remove: []string{"en.lproj/InfoPlist.strings", "en.lproj/Localizable.strings"}, dir: "en.lproj", n: 1, want: nil},
+		{name: "removed directory entry", remove: []string{"Frameworks/A.framework"}, dir: "Frameworks", n: 1, want: []string{"B.dylib"}},
+		{name: "root", remove: []string{"Ex", "Frameworks", "PlugIns", "_CodeSignature"}, dir: ".", n: 2, want: []string{"Empty", "Info.plist", "en.lproj"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := MaskFS(testBundleFS())
+
+			for _, name := range tt.remove {
+				if err := fsys.RemoveAll(name); err != nil {
+					t.Fatalf("RemoveAll(%q) = %v", name, err)
+				}
+			}
+
+			// Read via the opened directory
+			file, err := fsys.Open(tt.dir)
+			if err != nil {
+				t.Fatalf("Open(%q) = %v", tt.dir, err)
+			}
+
+			defer file.Close()
+
+			dir, ok := file.(fs.ReadDirFile)
+			if !ok {
+				t.Fatalf("Open(%q) doesn't return a directory", tt.dir)
+			}
+
+			var got []string
+
+			for {
+				entries, err := dir.ReadDir(tt.n)
+				for _, e := range entries {
+					got = append(got, e.Name())
+				}
+
+				if err == io.EOF || (tt.n <= 0 && err == nil) {
+					break
+				}
+
+				if err != nil {
+					t.Fatalf("ReadDir(%d) = %v", tt.n, err)
+				}
+
+				if len(entries) == 0 {
+					t.Fatalf("ReadDir(%d) returned no entries without io.EOF", tt.n)
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ReadDir(%d) = %q, want %q", tt.n, got, tt.want)
+			}
+
+			// Read via the filesystem
+			entries, err := fs.ReadDir(fsys, tt.dir)
+			if err != nil {
+				t.Fatalf("fs.ReadDir(%q) = %v", tt.dir, err)
+			}
+
+			got = nil
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fs.ReadDir(%q) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskFSRemove(t *testing.T) {
+	tests := []struct {
+		name    string   // name is the name of the test.
+		remove  []string // remove are the paths removed via Remove before name.
+		target  string   // target is the path removed.
+		wantErr error    // wantErr is the expected error, nil if removing succeeds.
+	}{
+		{name: "file", remove: nil, target: "Ex", wantErr: nil},
+		{name: "empty directory", remove: nil, target: "Empty", wantErr: nil},
+		{name: "non-empty directory", remove: nil, target: "en.lproj", wantErr: errDirNotEmpty},
+		{name: "emptied directory", remove: []string{"en.lproj/InfoPlist.strings", "en.lproj/Localizable.strings"}, target: "en.lproj", wantErr: nil},
+		{name: "partly emptied directory", remove: []string{"en.lproj/InfoPlist.strings"}, target: "en.lproj", wantErr: errDirNotEmpty},
+		{name: "missing file", remove: nil, target: "Missing", wantErr: fs.ErrNotExist},
+		{name: "removed file", remove: []string{"Ex"}, target: "Ex", wantErr: fs.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := MaskFS(testBundleFS())
+
+			for _, name := range tt.remove {
+				if err := fsys.Remove(name); err != nil {
+					t.Fatalf("Remove(%q) = %v", name, err)
+				}
+			}
+
+			err := fsys.Remove(tt.target)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Remove(%q) = %v, want %v", tt.target, err, tt.wantErr)
+			}
+
+			// Removed paths must be hidden, others must still be there
+			_, err = fs.Stat(fsys, tt.target)
+			if tt.wantErr == nil && !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Stat(%q) = %v after removal, want %v", tt.target, err, fs.ErrNotExist)
+			}
+
+			if errors.Is(tt.wantErr, errDirNotEmpty) && err != nil {
+				t.Errorf("Stat(%q) = %v after failed removal, want no error", tt.target, err)
+			}
+		})
+	}
+}
+
+func TestReadFileRange(t *testing.T) {
+	fsys := fstest.MapFS{"file": {Data: []byte("0123456789")}}
+
+	tests := []struct {
+		name    string // name is the name of the test.
+		off     int64  // off is the offset of the range.
+		size    int    // size is the size of the range.
+		want    string // want is the expected content.
+		wantErr error  // wantErr is the expected error, nil if reading succeeds.
+	}{
+		{name: "whole file", off: 0, size: 10, want: "0123456789"},
+		{name: "middle", off: 3, size: 4, want: "3456"},
+		{name: "empty at end", off: 10, size: 0, want: ""},
+		{name: "beyond end", off: 8, size: 3, wantErr: errRangeOutOfFile},
+		{name: "huge size", off: 0, size: 1 << 40, wantErr: errRangeOutOfFile},
+		{name: "negative offset", off: -1, size: 1, wantErr: errRangeOutOfFile},
+		{name: "missing file", off: 0, size: 1, wantErr: fs.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "file"
+			if tt.wantErr == fs.ErrNotExist {
+				name = "missing"
+			}
+
+			got, err := readFileRange(fsys, name, tt.off, tt.size)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("readFileRange() = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && string(got) != tt.want {
+				t.Errorf("readFileRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package decrypt
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+// writeIPA packages the app bundle fsys into an IPA file at output, as "Payload/<bundleName>".
+func writeIPA(ctx context.Context, fsys fs.FS, bundleName string, output string) error {
+	// Create IPA file
+	file, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("create IPA file: %w", err)
+	}
+
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+
+	// Add payload directory
+	hdr := &zip.FileHeader{Name: "Payload/", Modified: time.Now()}
+	hdr.SetMode(fs.ModeDir | 0755)
+
+	_, err = w.CreateHeader(hdr)
+	if err != nil {
+		return fmt.Errorf("create archive entry [%s]: %w", hdr.Name, err)
+	}
+
+	// Add all files and directories recursively
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Build archive header
+		info, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("get file info: %w", err)
+		}
+
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fmt.Errorf("create archive header: %w", err)
+		}
+
+		hdr.Name = path.Join("Payload", bundleName, p)
+
+		if d.IsDir() {
+			hdr.Name += "/"
+		} else {
+			hdr.Method = zip.Deflate
+		}
+
+		// Write entry
+		entry, err := w.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("create archive entry [%s]: %w", hdr.Name, err)
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		src, err := fsys.Open(p)
+		if err != nil {
+			return fmt.Errorf("open file: %w", err)
+		}
+
+		defer src.Close()
+
+		_, err = io.Copy(entry, src)
+		if err != nil {
+			return fmt.Errorf("copy content [%s]: %w", hdr.Name, err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("walk directory: %w", err)
+	}
+
+	// Finish archive
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("finish archive: %w", err)
+	}
+
+	return file.Close()
+}
+
+// ErrNoAppBundle is returned if an IPA file doesn't contain exactly one app bundle in its "Payload" directory.
+var ErrNoAppBundle = errors.New("no app bundle")
+
+// IPA is an IPA file opened for reading. Its app bundle can be processed without extracting it.
+type IPA struct {
+	zip    *zip.ReadCloser // zip is the opened archive.
+	bundle WritableFS      // bundle is the app bundle within the archive.
+
+	BundleName string // BundleName is the name of the app bundle directory (e.g. "Example.app").
+}
+
+// OpenIPA opens an IPA file, and locates the app bundle at "Payload/*.app".
+func OpenIPA(name string) (*IPA, error) {
+	// Open archive
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+
+	// Locate app bundle
+	bundles, err := fs.Glob(zr, "Payload/*.app")
+	if err != nil {
+		zr.Close() //nolint:errcheck
+		return nil, fmt.Errorf("locate app bundle: %w", err)
+	}
+
+	if len(bundles) != 1 {
+		zr.Close() //nolint:errcheck
+		return nil, fmt.Errorf("%w: found %d app bundles in \"Payload\"", ErrNoAppBundle, len(bundles))
+	}
+
+	bundle, err := fs.Sub(zr, bundles[0])
+	if err != nil {
+		zr.Close() //nolint:errcheck
+		return nil, fmt.Errorf("open app bundle: %w", err)
+	}
+
+	return &IPA{zip: zr, bundle: MaskFS(bundle), BundleName: path.Base(bundles[0])}, nil
+}
+
+// Bundle returns the app bundle as a filesystem, with paths relative to the bundle directory. Removing files from it
+// leaves the IPA file unchanged, but omits them when repackaging it.
+func (ipa *IPA) Bundle() WritableFS {
+	return ipa.bundle
+}
+
+// Repackage writes the app bundle into a new IPA file at output.
+func (ipa *IPA) Repackage(ctx context.Context, output string) error {
+	return writeIPA(ctx, ipa.bundle, ipa.BundleName, output)
+}
+
+// Close closes the IPA file.
+func (ipa *IPA) Close() error {
+	return ipa.zip.Close()
+}
@@ -0,0 +1,266 @@
+package decrypt
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const (
+	// MH_MAGIC_64 is the magic number for 64-bit Mach-O binaries.
+	MH_MAGIC_64 = 0xFEEDFACF
+
+	// MH_EXECUTE is the file type for executable Mach-O binaries.
+	MH_EXECUTE = 2
+
+	// MH_DYLIB is the file type for dynamically bound shared libraries.
+	MH_DYLIB = 6
+
+	// MH_BUNDLE is the file type for dynamically bound bundles (e.g. plugins).
+	MH_BUNDLE = 8
+
+	// CPU_TYPE_ARM64 is the CPU type of 64-bit ARM binaries.
+	CPU_TYPE_ARM64 = 0x0100000C
+
+	// CPU_SUBTYPE_ARM64E is the CPU subtype of 64-bit ARM binaries with pointer authentication.
+	CPU_SUBTYPE_ARM64E = 2
+
+	// CPU_SUBTYPE_MASK masks the capability bits of a CPU subtype.
+	CPU_SUBTYPE_MASK = 0xFF000000
+
+	// LC_ENCRYPTION_INFO_64 is the load command type for encryption info in 64-bit Mach-O binaries.
+	LC_ENCRYPTION_INFO_64 = 44
+
+	// LC_VERSION_MIN_IPHONEOS is the load command type for the minimum iOS version of older binaries.
+	LC_VERSION_MIN_IPHONEOS = 0x25
+
+	// LC_BUILD_VERSION is the load command type for the platform and minimum OS version of a binary.
+	LC_BUILD_VERSION = 0x32
+
+	// LC_CODE_SIGNATURE is the load command type for the location of the code signature in __LINKEDIT.
+	LC_CODE_SIGNATURE = 0x1D
+
+	// LC_SEGMENT_64 is the load command type for segments of 64-bit Mach-O binaries.
+	LC_SEGMENT_64 = 0x19
+)
+
+// maxLoadCmdSize is the maximum size of the load commands accepted by parseMachO, which bounds the memory allocated
+// for them (real binaries have a few kilobytes of load commands at most).
+const maxLoadCmdSize = 16 << 20
+
+// MachOInfo holds information about a Mach-O binary and its encryption status.
+type MachOInfo struct {
+	Path                 string         // Path to the Mach-O binary
+	FileType             uint32         // Type of the Mach-O binary (e.g., MH_EXECUTE, MH_DYLIB)
+	CPUType              uint32         // CPU type of the Mach-O binary (e.g., CPU_TYPE_ARM64)
+	CPUSubtype           uint32         // CPU subtype of the Mach-O binary (e.g., CPU_SUBTYPE_ARM64E)
+	CryptCommandOffset   uint64         // Offset of the LC_ENCRYPTION_INFO load command
+	CryptOffset          uint32         // Offset of the encrypted range
+	CryptSize            uint32         // Size of the encrypted range
+	CryptID              uint32         // Encryption system ID
+	MinOS                string         // Minimum OS version required by the binary (empty if unknown)
+	CodeSigCommandOffset uint64         // Offset of the LC_CODE_SIGNATURE load command (zero if unsigned)
+	CodeSigOffset        uint32         // Offset of the code signature
+	CodeSigSize          uint32         // Size of the code signature
+	Signature            *CodeSignature // Parsed code signature (only set by ScanBinaries, nil if unsigned)
+}
+
+// machOHeader represents the header of a Mach-O binary.
+type machOHeader struct {
+	Magic        uint32  // Magic number
+	CPUType      uint32  // CPU type
+	CPUSubtype   uint32  // CPU subtype
+	FileType     uint32  // File type
+	LoadCmdCount uint32  // Number of load commands
+	LoadCmdSize  uint32  // Size of load commands
+	Flags        uint32  // Flags
+	_            [4]byte // Padding
+}
+
+// machOLoadCmd represents a load command in a Mach-O binary.
+type machOLoadCmd struct {
+	Type uint32 // Command type
+	Size uint32 // Size of the command
+}
+
+// machOEncryptionInfo represents the encryption info load command in a Mach-O binary.
+type machOEncryptionInfo struct {
+	CryptOffset uint32  // Offset of encrypted range
+	CryptSize   uint32  // Size of encrypted range
+	CryptID     uint32  // Encryption system ID
+	_           [4]byte // Padding
+}
+
+// parseMachO parses the header and load commands of a Mach-O binary read from r. It returns nil if the data is not
+// a (complete) thin 64-bit Mach-O binary. Only the beginning of the binary up to the end of the load commands is read,
+// so r doesn't need to be seekable.
+func parseMachO(r io.Reader, path string) (*MachOInfo, error) {
+	// Read header
+	var h machOHeader
+
+	err := binary.Read(r, binary.LittleEndian, &h)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	if h.Magic != MH_MAGIC_64 {
+		return nil, nil
+	}
+
+	// Read load commands
+	if h.LoadCmdSize > maxLoadCmdSize {
+		return nil, fmt.Errorf("invalid size of load commands: %d bytes", h.LoadCmdSize)
+	}
+
+	cmds := make([]byte, h.LoadCmdSize)
+
+	_, err = io.ReadFull(r, cmds)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read load commands: %w", err)
+	}
+
+	info := &MachOInfo{
+		Path:        path,
+		FileType:    h.FileType,
+		CPUType:     h.CPUType,
+		CPUSubtype:  h.CPUSubtype,
+		CryptOffset: 0,
+		CryptSize:   0,
+		CryptID:     0,
+	}
+
+	offset := uint64(unsafe.Sizeof(h))
+
+	for i := range h.LoadCmdCount {
+		// Read load command header
+		var lc machOLoadCmd
+
+		if len(cmds) < int(unsafe.Sizeof(lc)) {
+			return nil, fmt.Errorf("read load command [%d]: %w", i, io.ErrUnexpectedEOF)
+		}
+
+		lc.Type = binary.LittleEndian.Uint32(cmds[0:])
+		lc.Size = binary.LittleEndian.Uint32(cmds[4:])
+
+		if lc.Size < uint32(unsafe.Sizeof(lc)) || int(lc.Size) > len(cmds) {
+			return nil, fmt.Errorf("invalid size of load command [%d]: %d bytes", i, lc.Size)
+		}
+
+		// Set minimum OS version (the first field of both commands, encoded as nibbles "xxxx.yy.zz")
+		if (lc.Type == LC_BUILD_VERSION || lc.Type == LC_VERSION_MIN_IPHONEOS) && lc.Size >= 16 {
+			field := 8
+			if lc.Type == LC_BUILD_VERSION {
+				field = 12
+			}
+
+			info.MinOS = formatMachOVersion(binary.LittleEndian.Uint32(cmds[field:]))
+		}
+
+		// Set code signature location
+		if lc.Type == LC_CODE_SIGNATURE && lc.Size >= 16 {
+			info.CodeSigCommandOffset = offset
+			info.CodeSigOffset = binary.LittleEndian.Uint32(cmds[8:])
+			info.CodeSigSize = binary.LittleEndian.Uint32(cmds[12:])
+		}
+
+		// Set encryption info
+		if lc.Type == LC_ENCRYPTION_INFO_64 {
+			var ei machOEncryptionInfo
+
+			err = binary.Read(bytes.NewReader(cmds[unsafe.Sizeof(lc):lc.Size]), binary.LittleEndian, &ei)
+			if err != nil {
+				return nil, fmt.Errorf("read encryption info: %w", err)
+			}
+
+			info.CryptCommandOffset = offset
+			info.CryptOffset = ei.CryptOffset
+			info.CryptSize = ei.CryptSize
+			info.CryptID = ei.CryptID
+		}
+
+		// Advance to the next load command
+		cmds = cmds[lc.Size:]
+		offset += uint64(lc.Size)
+	}
+
+	return info, nil
+}
+
+// SupportedOn returns true if the binary can be loaded on a device running osVersion. Binaries without a minimum OS
+// version are assumed to be supported, as is every binary if osVersion is unknown.
+func (info *MachOInfo) SupportedOn(osVersion string) bool {
+	return info.MinOS == "" || osVersion == "" || compareVersions(info.MinOS, osVersion) <= 0
+}
+
+// FileTypeName returns a readable name of the Mach-O file type (e.g. "executable").
+func (info *MachOInfo) FileTypeName() string {
+	switch info.FileType {
+	case MH_EXECUTE:
+		return "executable"
+	case MH_DYLIB:
+		return "dylib"
+	case MH_BUNDLE:
+		return "bundle"
+	default:
+		return fmt.Sprintf("0x%x", info.FileType)
+	}
+}
+
+// SliceName returns a readable name of the architecture of the Mach-O binary (e.g. "arm64e").
+func (info *MachOInfo) SliceName() string {
+	if info.CPUType != CPU_TYPE_ARM64 {
+		return fmt.Sprintf("0x%x/0x%x", info.CPUType, info.CPUSubtype)
+	}
+
+	if info.CPUSubtype&^CPU_SUBTYPE_MASK == CPU_SUBTYPE_ARM64E {
+		return "arm64e"
+	}
+
+	return "arm64"
+}
+
+// formatMachOVersion formats a version encoded as nibbles "xxxx.yy.zz" (e.g. "15.0" or "16.4.1").
+func formatMachOVersion(v uint32) string {
+	if v&0xFF == 0 {
+		return fmt.Sprintf("%d.%d", v>>16, (v>>8)&0xFF)
+	}
+
+	return fmt.Sprintf("%d.%d.%d", v>>16, (v>>8)&0xFF, v&0xFF)
+}
+
+// compareVersions compares two dotted version strings numerically (e.g. "15.0" < "15.0.1" < "16.4"). It returns -1,
+// 0 or +1, like strings.Compare.
+func compareVersions(a string, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+
+		if c := cmp.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
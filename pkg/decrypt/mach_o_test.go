@@ -0,0 +1,221 @@
+package decrypt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+const (
+	// testTextSize is the size of the __TEXT segment of binaries built by testBinary.
+	testTextSize = 0x8000
+
+	// testSectionOffset is the offset of the first section of binaries built by testBinary.
+	testSectionOffset = 0x1000
+
+	// testLinkeditCommandOffset is the offset of the __LINKEDIT segment command of binaries built by testBinary.
+	testLinkeditCommandOffset = 32 + 72 + 80
+
+	// testCodeSigCommandOffset is the offset of the LC_CODE_SIGNATURE load command of binaries built by testBinary.
+	testCodeSigCommandOffset = testLinkeditCommandOffset + 72
+)
+
+// testMachO builds a thin 64-bit ARM Mach-O binary with the given file type and load commands.
+func testMachO(fileType uint32, cmds ...[]byte) []byte {
+	var size uint32
+	for _, cmd := range cmds {
+		size += uint32(len(cmd))
+	}
+
+	data := binary.LittleEndian.AppendUint32(nil, MH_MAGIC_64)
+	data = binary.LittleEndian.AppendUint32(data, CPU_TYPE_ARM64)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint32(data, fileType)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(cmds)))
+	data = binary.LittleEndian.AppendUint32(data, size)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+
+	for _, cmd := range cmds {
+		data = append(data, cmd...)
+	}
+
+	return data
+}
+
+// testLoadCommand builds a load command of the given type with 32-bit fields.
+func testLoadCommand(cmdType uint32, fields ...uint32) []byte {
+	cmd := binary.LittleEndian.AppendUint32(nil, cmdType)
+	cmd = binary.LittleEndian.AppendUint32(cmd, uint32(8+4*len(fields)))
+
+	for _, field := range fields {
+		cmd = binary.LittleEndian.AppendUint32(cmd, field)
+	}
+
+	return cmd
+}
+
+// testEncryptionInfo builds an LC_ENCRYPTION_INFO_64 load command.
+func testEncryptionInfo(cryptOffset uint32, cryptSize uint32, cryptID uint32) []byte {
+	return testLoadCommand(LC_ENCRYPTION_INFO_64, cryptOffset, cryptSize, cryptID, 0)
+}
+
+// testSegment builds an LC_SEGMENT_64 load command, with a section starting at each of the given file offsets.
+func testSegment(name string, vmAddr uint64, vmSize uint64, fileOff uint64, fileSize uint64, sectionOffsets ...uint32) []byte {
+	cmd := make([]byte, 72+80*len(sectionOffsets))
+
+	binary.LittleEndian.PutUint32(cmd[0:], LC_SEGMENT_64)
+	binary.LittleEndian.PutUint32(cmd[4:], uint32(len(cmd)))
+	copy(cmd[8:24], name)
+	binary.LittleEndian.PutUint64(cmd[24:], vmAddr)
+	binary.LittleEndian.PutUint64(cmd[32:], vmSize)
+	binary.LittleEndian.PutUint64(cmd[40:], fileOff)
+	binary.LittleEndian.PutUint64(cmd[48:], fileSize)
+	binary.LittleEndian.PutUint32(cmd[64:], uint32(len(sectionOffsets)))
+
+	for i, offset := range sectionOffsets {
+		section := cmd[72+80*i:]
+
+		copy(section[0:16], fmt.Sprintf("__sect%d", i))
+		copy(section[16:32], name)
+		binary.LittleEndian.PutUint32(section[48:], offset)
+	}
+
+	return cmd
+}
+
+// testBinary builds a thin 64-bit binary with a __TEXT segment of code and linkeditSize bytes of __LINKEDIT data. If
+// signature isn't nil, the __LINKEDIT data is followed by it as code signature (padded to end 16 byte aligned). The
+// binary ends with trailing bytes of other data.
+func testBinary(fileType uint32, linkeditSize int, signature []byte, trailing int) []byte {
+	linkeditEnd := testTextSize + linkeditSize
+
+	// Signed binaries reserve another page of __LINKEDIT for the code signature
+	linkeditVMSize := uint64(segmentPageSize)
+	if signature != nil {
+		linkeditEnd = (linkeditEnd + len(signature) + 15) &^ 15
+		linkeditVMSize *= 2
+	}
+
+	cmds := [][]byte{
+		testSegment("__TEXT", 0x100000000, testTextSize, 0, testTextSize, testSectionOffset),
+		testSegment(linkeditSegmentName, 0x100000000+testTextSize, linkeditVMSize, testTextSize, uint64(linkeditEnd-testTextSize)),
+	}
+
+	if signature != nil {
+		cmds = append(cmds, testLoadCommand(LC_CODE_SIGNATURE, uint32(testTextSize+linkeditSize), uint32(len(signature))))
+	}
+
+	data := testMachO(fileType, cmds...)
+
+	// Code, __LINKEDIT data, code signature and trailing data
+	data = append(data, make([]byte, testSectionOffset-len(data))...)
+
+	for i := len(data); i < testTextSize; i++ {
+		data = append(data, byte(i*7+i>>8))
+	}
+
+	data = append(data, bytes.Repeat([]byte{0x11}, linkeditSize)...)
+	data = append(data, signature...)
+	data = append(data, make([]byte, linkeditEnd-len(data))...)
+
+	return append(data, bytes.Repeat([]byte{0x22}, trailing)...)
+}
+
+func TestParseMachO(t *testing.T) {
+	signed := testMachO(MH_EXECUTE,
+		testLoadCommand(LC_BUILD_VERSION, 2, 0x100400, 0x110000, 0),
+		testEncryptionInfo(0x4000, 0x8000, 1),
+		testLoadCommand(LC_CODE_SIGNATURE, 0x10000, 0x1230))
+
+	oversized := testMachO(MH_EXECUTE)
+	binary.LittleEndian.PutUint32(oversized[20:], maxLoadCmdSize+1)
+
+	missing := testMachO(MH_EXECUTE, testLoadCommand(0x7FFF))
+	binary.LittleEndian.PutUint32(missing[16:], 2)
+
+	tests := []struct {
+		name    string     // name is the name of the test.
+		data    []byte     // data is the parsed binary.
+		want    *MachOInfo // want is the expected information, nil if the data is not a Mach-O binary.
+		wantErr bool       // wantErr is true if parsing is expected to fail.
+	}{
+		{
+			name: "encrypted and signed executable",
+			data: signed,
+			want: &MachOInfo{
+				Path: "Ex", FileType: MH_EXECUTE, CPUType: CPU_TYPE_ARM64, MinOS: "16.4",
+				CryptCommandOffset: 32 + 24, CryptOffset: 0x4000, CryptSize: 0x8000, CryptID: 1,
+				CodeSigCommandOffset: 32 + 24 + 24, CodeSigOffset: 0x10000, CodeSigSize: 0x1230,
+			},
+		},
+		{
+			name: "legacy minimum version",
+			data: testMachO(MH_DYLIB, testLoadCommand(LC_VERSION_MIN_IPHONEOS, 0x0C0102, 0)),
+			want: &MachOInfo{Path: "Ex", FileType: MH_DYLIB, CPUType: CPU_TYPE_ARM64, MinOS: "12.1.2"},
+		},
+		{
+			name: "unknown load commands",
+			data: testMachO(MH_BUNDLE, testLoadCommand(0x7FFF, 1, 2, 3, 4)),
+			want: &MachOInfo{Path: "Ex", FileType: MH_BUNDLE, CPUType: CPU_TYPE_ARM64},
+		},
+		{name: "fat binary", data: append(binary.BigEndian.AppendUint32(nil, 0xCAFEBABE), make([]byte, 60)...), want: nil},
+		{name: "text file", data: []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"), want: nil},
+		{name: "truncated header", data: signed[:16], want: nil},
+		{name: "truncated load commands", data: signed[:40], want: nil},
+		{name: "oversized load commands", data: oversized, wantErr: true},
+		{name: "load command too small", data: testMachO(MH_EXECUTE, []byte{1, 0, 0, 0, 4, 0, 0, 0}), wantErr: true},
+		{name: "load command too large", data: testMachO(MH_EXECUTE, []byte{1, 0, 0, 0, 16, 0, 0, 0}), wantErr: true},
+		{name: "missing load command", data: missing, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseMachO(bufio.NewReader(bytes.NewReader(tt.data)), "Ex")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseMachO() = %+v, want error", info)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseMachO() = %v", err)
+			}
+
+			if tt.want == nil || info == nil {
+				if tt.want != info {
+					t.Fatalf("parseMachO() = %+v, want %+v", info, tt.want)
+				}
+
+				return
+			}
+
+			if *info != *tt.want {
+				t.Errorf("parseMachO() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string // a and b are the compared versions.
+		want int    // want is the expected result.
+	}{
+		{a: "15.0", b: "15.0", want: 0},
+		{a: "15", b: "15.0.0", want: 0},
+		{a: "15.0", b: "15.0.1", want: -1},
+		{a: "16.4", b: "16.10", want: -1},
+		{a: "17.0", b: "16.7.8", want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// cryptIDOffset is the offset of the cryptid field within the LC_ENCRYPTION_INFO_64 load command.
+const cryptIDOffset = 16
+
+// dumpBinaries spawns the application and replaces the encrypted range of each binary in the local app bundle at
+// root with its decrypted counterpart from memory. The cryptid of every dumped binary is reset to zero, and reported as
+// read back from the file. A result is returned for every binary dumped before an error occurred.
+func (app *Application) dumpBinaries(ctx context.Context, root string, binaries map[string]*MachOInfo, progress Progress) ([]BinaryResult, error) {
+	// Nothing to do without encrypted binaries
+	if len(binaries) == 0 {
+		return nil, nil
+	}
+
+	// Load script
+	content, err := readScript("dump.js")
+	if err != nil {
+		return nil, fmt.Errorf("read dump script: %w", err)
+	}
+
+	// Spawn application with script loaded before its first instruction
+	script, pid, err := app.device.SpawnScript(ctx, "dump.js", content, app.Identifier)
+	if err != nil {
+		return nil, fmt.Errorf("spawn application: %w", err)
+	}
+
+	defer app.device.device.Kill(pid) //nolint
+	defer script.Close()
+
+	// Dump every binary
+	var total int64
+
+	for _, info := range binaries {
+		total += int64(info.CryptSize)
+	}
+
+	progress.Start("Decrypting binaries", total)
+	defer progress.Stop()
+
+	bundle := path.Base(app.Path)
+	results := make([]BinaryResult, 0, len(binaries))
+
+	for _, info := range binaries {
+		progress.UpdateTitle(fmt.Sprintf("Decrypting %s (%d/%d binaries)", info.Path, len(results)+1, len(binaries)))
+
+		size, cryptID, err := dumpBinary(ctx, script, filepath.Join(root, info.Path), bundle+"/"+filepath.ToSlash(info.Path), app.Path+"/"+filepath.ToSlash(info.Path), info, progress)
+		if err != nil {
+			return results, fmt.Errorf("dump binary [%s]: %w", info.Path, err)
+		}
+
+		results = append(results, BinaryResult{
+			Path:           info.Path,
+			FileType:       info.FileTypeName(),
+			Slice:          info.SliceName(),
+			CryptIDBefore:  info.CryptID,
+			CryptIDAfter:   cryptID,
+			BytesDecrypted: size,
+		})
+
+		slog.Info("Decrypted binary", slog.String("path", info.Path), slog.Int64("size", size))
+	}
+
+	return results, nil
+}
+
+// dumpBinary replaces the encrypted range of a single local binary with the decrypted range from memory, and
+// returns the number of bytes replaced and the cryptid read back from the patched file.
+func dumpBinary(ctx context.Context, script *Script, localPath string, modulePath string, remotePath string, info *MachOInfo, progress Progress) (int64, uint32, error) {
+	// Open local file
+	file, err := os.OpenFile(localPath, os.O_RDWR, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open local file: %w", err)
+	}
+
+	defer file.Close()
+
+	// Write decrypted chunks to the encrypted range
+	var writeErr error
+
+	unsubscribe := script.Subscribe("chunk", func(payload map[string]any, data []byte) {
+		offset, _ := payload["offset"].(float64)
+
+		if writeErr == nil {
+			_, writeErr = file.WriteAt(data, int64(info.CryptOffset)+int64(offset))
+			progress.Add(int64(len(data)))
+		}
+	})
+
+	defer unsubscribe()
+
+	size, err := CallAs[uint32](ctx, script, "dump", modulePath, remotePath, info.CryptOffset, info.CryptSize, fridaChunkSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dump decrypted range: %w", err)
+	}
+
+	if writeErr != nil {
+		return 0, 0, fmt.Errorf("write decrypted range: %w", writeErr)
+	}
+
+	if size != info.CryptSize {
+		return 0, 0, fmt.Errorf("size mismatch: expected %d bytes, got %d bytes", info.CryptSize, size)
+	}
+
+	// Reset cryptid
+	cryptID := binary.LittleEndian.AppendUint32(nil, 0)
+
+	_, err = file.WriteAt(cryptID, int64(info.CryptCommandOffset)+cryptIDOffset)
+	if err != nil {
+		return 0, 0, fmt.Errorf("reset cryptid: %w", err)
+	}
+
+	// Read cryptid back
+	_, err = file.ReadAt(cryptID, int64(info.CryptCommandOffset)+cryptIDOffset)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read back cryptid: %w", err)
+	}
+
+	return int64(size), binary.LittleEndian.Uint32(cryptID), file.Close()
+}
@@ -0,0 +1,569 @@
+package decrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// binaryPlistMagic is the header of binary property lists.
+const binaryPlistMagic = "bplist00"
+
+// binaryPlistEpoch is the reference date of dates in binary property lists.
+var binaryPlistEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// maxBinaryPlistObjects is the maximum number of objects in a binary property list.
+const maxBinaryPlistObjects = 1 << 20
+
+// parsePlist parses an XML or binary property list, returning the same types as parseXMLPlist.
+func parsePlist(data []byte) (any, error) {
+	if bytes.HasPrefix(data, []byte(binaryPlistMagic)) {
+		return parseBinaryPlist(data)
+	}
+
+	return parseXMLPlist(data)
+}
+
+// parseXMLPlist parses an XML property list. Dictionaries are returned as map[string]any, arrays as []any, and the
+// remaining types as string, int64, float64, bool, time.Time and []byte.
+func parseXMLPlist(data []byte) (any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	// Find root element
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("no plist element found")
+			}
+
+			return nil, fmt.Errorf("read token: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name.Local != "plist" {
+			return nil, fmt.Errorf("unexpected root element [%s]", start.Name.Local)
+		}
+
+		break
+	}
+
+	// Parse value below root element
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("read token: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return parsePlistValue(dec, tok)
+		case xml.EndElement:
+			return nil, fmt.Errorf("empty plist")
+		}
+	}
+}
+
+// parsePlistValue parses the value of the element that has just been started.
+func parsePlistValue(dec *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]any)
+
+		var key *string
+
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("read token: %w", err)
+			}
+
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				if tok.Name.Local == "key" {
+					var k string
+
+					err := dec.DecodeElement(&k, &tok)
+					if err != nil {
+						return nil, fmt.Errorf("decode key: %w", err)
+					}
+
+					key = &k
+
+					continue
+				}
+
+				if key == nil {
+					return nil, fmt.Errorf("value without key in dict")
+				}
+
+				value, err := parsePlistValue(dec, tok)
+				if err != nil {
+					return nil, fmt.Errorf("parse value [%s]: %w", *key, err)
+				}
+
+				dict[*key], key = value, nil
+
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+
+	case "array":
+		array := []any{}
+
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fmt.Errorf("read token: %w", err)
+			}
+
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				value, err := parsePlistValue(dec, tok)
+				if err != nil {
+					return nil, fmt.Errorf("parse array element [%d]: %w", len(array), err)
+				}
+
+				array = append(array, value)
+
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+
+	case "true", "false":
+		err := dec.Skip()
+		if err != nil {
+			return nil, err
+		}
+
+		return start.Name.Local == "true", nil
+	}
+
+	// Parse scalar value
+	var text string
+
+	err := dec.DecodeElement(&text, &start)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", start.Name.Local, err)
+	}
+
+	text = strings.TrimSpace(text)
+
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(text, 10, 64)
+	case "real":
+		return strconv.ParseFloat(text, 64)
+	case "date":
+		return time.Parse(time.RFC3339, text)
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	default:
+		return nil, fmt.Errorf("unknown element [%s]", start.Name.Local)
+	}
+}
+
+// binaryPlist is a binary property list being parsed.
+type binaryPlist struct {
+	objects []byte   // objects is the part of the property list before the offset table.
+	offsets []uint64 // offsets are the offsets of all objects, by reference.
+	refSize int      // refSize is the size of object references in bytes.
+	parsing []bool   // parsing marks the objects currently being parsed, by reference, to detect cycles.
+	parsed  []bool   // parsed marks the objects that have been parsed, by reference.
+	values  []any    // values are the parsed objects, by reference.
+}
+
+// parseBinaryPlist parses a binary property list ("bplist00"), returning the same types as parseXMLPlist. UIDs are
+// returned as int64, sets as []any. Every object is only parsed once, so objects referenced several times are shared
+// within the returned value.
+func parseBinaryPlist(data []byte) (any, error) {
+	// Parse trailer
+	if len(data) < len(binaryPlistMagic)+32 || !bytes.HasPrefix(data, []byte(binaryPlistMagic)) {
+		return nil, fmt.Errorf("not a binary plist")
+	}
+
+	trailer := data[len(data)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	count := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 {
+		return nil, fmt.Errorf("invalid integer sizes [%d/%d]", offsetSize, refSize)
+	}
+
+	if count > maxBinaryPlistObjects {
+		return nil, fmt.Errorf("too many objects [%d]", count)
+	}
+
+	tableEnd := uint64(len(data) - len(trailer))
+	if tableOffset < uint64(len(binaryPlistMagic)) || tableOffset > tableEnd || count > (tableEnd-tableOffset)/uint64(offsetSize) {
+		return nil, fmt.Errorf("invalid offset table")
+	}
+
+	// Parse offset table
+	p := &binaryPlist{
+		objects: data[:tableOffset],
+		offsets: make([]uint64, count),
+		refSize: refSize,
+		parsing: make([]bool, count),
+		parsed:  make([]bool, count),
+		values:  make([]any, count),
+	}
+
+	for i := range p.offsets {
+		p.offsets[i] = bigEndianUint(data[tableOffset+uint64(i*offsetSize):][:offsetSize])
+	}
+
+	return p.object(top)
+}
+
+// object returns the object with the given reference, parsing it on first use.
+func (p *binaryPlist) object(ref uint64) (any, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("invalid object reference: %d", ref)
+	}
+
+	if p.parsed[ref] {
+		return p.values[ref], nil
+	}
+
+	if p.parsing[ref] {
+		return nil, fmt.Errorf("cyclic object reference: %d", ref)
+	}
+
+	// Parse object
+	p.parsing[ref] = true
+	value, err := p.parse(ref)
+	p.parsing[ref] = false
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.parsed[ref] = true
+	p.values[ref] = value
+
+	return value, nil
+}
+
+// parse parses the object with the given reference.
+func (p *binaryPlist) parse(ref uint64) (any, error) {
+	offset := p.offsets[ref]
+	if offset < uint64(len(binaryPlistMagic)) || offset >= uint64(len(p.objects)) {
+		return nil, fmt.Errorf("invalid offset of object [%d]: %d", ref, offset)
+	}
+
+	marker, body := p.objects[offset], p.objects[offset+1:]
+	info := int(marker & 0x0F)
+
+	switch marker >> 4 {
+	case 0x0:
+		switch marker {
+		case 0x00:
+			return nil, nil
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+
+	case 0x1:
+		if info > 4 {
+			break
+		}
+
+		b, err := takeBytes(body, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+
+		// 16 byte integers are only used for unsigned 64-bit values
+		return int64(bigEndianUint(b[max(0, len(b)-8):])), nil
+
+	case 0x2:
+		if info != 2 && info != 3 {
+			break
+		}
+
+		b, err := takeBytes(body, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+
+		if info == 2 {
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+
+	case 0x3:
+		if marker != 0x33 {
+			break
+		}
+
+		b, err := takeBytes(body, 8)
+		if err != nil {
+			return nil, err
+		}
+
+		seconds := math.Float64frombits(binary.BigEndian.Uint64(b))
+
+		return binaryPlistEpoch.Add(time.Duration(seconds * float64(time.Second))), nil
+
+	case 0x4, 0x5, 0x6:
+		n, body, err := p.length(info, body)
+		if err != nil {
+			return nil, err
+		}
+
+		if marker>>4 == 0x6 {
+			n *= 2
+		}
+
+		b, err := takeBytes(body, n)
+		if err != nil {
+			return nil, err
+		}
+
+		switch marker >> 4 {
+		case 0x4:
+			return bytes.Clone(b), nil
+		case 0x5:
+			return string(b), nil
+		}
+
+		units := make([]uint16, n/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+
+		return string(utf16.Decode(units)), nil
+
+	case 0x8:
+		b, err := takeBytes(body, info+1)
+		if err != nil {
+			return nil, err
+		}
+
+		return int64(bigEndianUint(b)), nil
+
+	case 0xA, 0xC:
+		n, body, err := p.length(info, body)
+		if err != nil {
+			return nil, err
+		}
+
+		refs, err := p.refs(body, n)
+		if err != nil {
+			return nil, err
+		}
+
+		array := make([]any, 0, n)
+
+		for i, ref := range refs {
+			value, err := p.object(ref)
+			if err != nil {
+				return nil, fmt.Errorf("parse array element [%d]: %w", i, err)
+			}
+
+			array = append(array, value)
+		}
+
+		return array, nil
+
+	case 0xD:
+		n, body, err := p.length(info, body)
+		if err != nil {
+			return nil, err
+		}
+
+		refs, err := p.refs(body, 2*n)
+		if err != nil {
+			return nil, err
+		}
+
+		dict := make(map[string]any, n)
+
+		for i := range n {
+			key, err := p.object(refs[i])
+			if err != nil {
+				return nil, fmt.Errorf("parse key: %w", err)
+			}
+
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key is not a string [%v]", key)
+			}
+
+			dict[k], err = p.object(refs[n+i])
+			if err != nil {
+				return nil, fmt.Errorf("parse value [%s]: %w", k, err)
+			}
+		}
+
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("unknown object type [0x%02x]", marker)
+}
+
+// length returns the length of an object with the given marker info, which is either the info itself or an integer
+// object following the marker, and the remaining body.
+func (p *binaryPlist) length(info int, body []byte) (int, []byte, error) {
+	if info != 0x0F {
+		return info, body, nil
+	}
+
+	if len(body) == 0 || body[0]>>4 != 0x1 || body[0]&0x0F > 3 {
+		return 0, nil, fmt.Errorf("invalid object length")
+	}
+
+	size := 1 << (body[0] & 0x0F)
+
+	b, err := takeBytes(body[1:], size)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n := bigEndianUint(b)
+	if n > uint64(len(p.objects)) {
+		return 0, nil, fmt.Errorf("invalid object length: %d", n)
+	}
+
+	return int(n), body[1+size:], nil
+}
+
+// refs returns n object references read from body.
+func (p *binaryPlist) refs(body []byte, n int) ([]uint64, error) {
+	b, err := takeBytes(body, n*p.refSize)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = bigEndianUint(b[i*p.refSize:][:p.refSize])
+	}
+
+	return refs, nil
+}
+
+// takeBytes returns the first n bytes of body.
+func takeBytes(body []byte, n int) ([]byte, error) {
+	if n < 0 || n > len(body) {
+		return nil, fmt.Errorf("object exceeds the property list")
+	}
+
+	return body[:n], nil
+}
+
+// bigEndianUint decodes a big-endian unsigned integer of up to 8 bytes.
+func bigEndianUint(b []byte) uint64 {
+	var v uint64
+
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
+
+// plistEscaper escapes text in XML property lists, leaving quotes as they are.
+var plistEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// encodeXMLPlist encodes a value as an XML property list. It supports the same types parseXMLPlist returns (except
+// dates), dictionaries are encoded with sorted keys.
+func encodeXMLPlist(value any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	buf.WriteString(`<plist version="1.0">` + "\n")
+
+	err := encodePlistValue(&buf, value, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString("</plist>\n")
+
+	return buf.Bytes(), nil
+}
+
+// encodePlistValue encodes a single value, indented by depth tabs.
+func encodePlistValue(buf *bytes.Buffer, value any, depth int) error {
+	indent := strings.Repeat("\t", depth)
+
+	switch v := value.(type) {
+	case map[string]any:
+		buf.WriteString(indent + "<dict>\n")
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			buf.WriteString(indent + "\t<key>" + plistEscaper.Replace(key) + "</key>\n")
+
+			err := encodePlistValue(buf, v[key], depth+1)
+			if err != nil {
+				return fmt.Errorf("encode value [%s]: %w", key, err)
+			}
+		}
+
+		buf.WriteString(indent + "</dict>\n")
+
+	case []any:
+		buf.WriteString(indent + "<array>\n")
+
+		for i, elem := range v {
+			err := encodePlistValue(buf, elem, depth+1)
+			if err != nil {
+				return fmt.Errorf("encode array element [%d]: %w", i, err)
+			}
+		}
+
+		buf.WriteString(indent + "</array>\n")
+
+	case string:
+		buf.WriteString(indent + "<string>" + plistEscaper.Replace(v) + "</string>\n")
+
+	case bool:
+		buf.WriteString(indent + "<" + strconv.FormatBool(v) + "/>\n")
+
+	case int64:
+		buf.WriteString(indent + "<integer>" + strconv.FormatInt(v, 10) + "</integer>\n")
+
+	case float64:
+		buf.WriteString(indent + "<real>" + strconv.FormatFloat(v, 'f', -1, 64) + "</real>\n")
+
+	case []byte:
+		buf.WriteString(indent + "<data>" + base64.StdEncoding.EncodeToString(v) + "</data>\n")
+
+	default:
+		return fmt.Errorf("unsupported type %T", value)
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package decrypt
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testBinaryPlist is a binary property list with all supported types, as written by Python's plistlib.
+const testBinaryPlist = "62706c6973743030df100f0102030405060708090a0b0c0d0e0f1015161718191a1b1c1d2021222324554172726179534269675f1012" +
+	"434642756e646c6545786563757461626c655f1012434642756e646c654964656e746966696572544461746154446174655546616c736553" +
+	"496e74544c6f6e67564e6573746564545265616c545472756551555355494457556e69636f6465a311121310015374776fa11410031300" +
+	"000100000000005245785e636f6d2e6578616d706c652e6578430001023341c5a1da528000000813fffffffffffffffb5f101478787878" +
+	"78787878787878787878787878787878d11e1f516b5176233ff80000000000000910ff8007670047007200fc00df00650020260300080029" +
+	"002f00330048005d00620067006d00710076007d008200870089008d00950099009b009f00a100a300ac00af00be00c200cb00cc00d500ec" +
+	"00ef00f100f300fc00fd00ff01010000000000000201000000000000002500000000000000000000000000000110"
+
+func TestParsePlist(t *testing.T) {
+	data, err := hex.DecodeString(testBinaryPlist)
+	if err != nil {
+		t.Fatalf("decode binary plist: %v", err)
+	}
+
+	want := map[string]any{
+		"CFBundleExecutable": "Ex",
+		"CFBundleIdentifier": "com.example.ex",
+		"Int":                int64(-5),
+		"Big":                int64(1 << 40),
+		"U":                  int64(255),
+		"Real":               1.5,
+		"True":               true,
+		"False":              false,
+		"Data":               []byte{0, 1, 2},
+		"Date":               time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"Unicode":            "Grüße ☃",
+		"Long":               "xxxxxxxxxxxxxxxxxxxx",
+		"Array":              []any{int64(1), "two", []any{int64(3)}},
+		"Nested":             map[string]any{"k": "v"},
+		"UID":                int64(7),
+	}
+
+	tests := []struct {
+		name string // name is the name of the test.
+		data []byte // data is the property list.
+		want any    // want is the expected value.
+	}{
+		{name: "binary", data: data, want: want},
+		{
+			name: "XML",
+			data: []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0"><dict><key>CFBundleExecutable</key><string>Ex</string><key>Int</key><integer>-5</integer></dict></plist>`),
+			want: map[string]any{"CFBundleExecutable": "Ex", "Int": int64(-5)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePlist(tt.data)
+			if err != nil {
+				t.Fatalf("parsePlist() = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePlist() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testBinaryPlistData returns a binary plist with the given object data and trailer fields.
+func testBinaryPlistData(objects []byte, offsets []byte, offsetSize, refSize byte, count, top, tableOffset uint64) []byte {
+	data := append([]byte(binaryPlistMagic), objects...)
+	data = append(data, offsets...)
+	data = append(data, 0, 0, 0, 0, 0, 0, offsetSize, refSize)
+
+	for _, v := range []uint64{count, top, tableOffset} {
+		data = append(data, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+
+	return data
+}
+
+func TestParseBinaryPlistInvalid(t *testing.T) {
+	valid, err := hex.DecodeString(testBinaryPlist)
+	if err != nil {
+		t.Fatalf("decode binary plist: %v", err)
+	}
+
+	tests := []struct {
+		name string // name is the name of the test.
+		data []byte // data is the property list.
+	}{
+		{name: "truncated", data: valid[:len(valid)-1]},
+		{name: "too short", data: []byte(binaryPlistMagic)},
+		{name: "invalid offset size", data: testBinaryPlistData([]byte{0x09}, []byte{8}, 0, 1, 1, 0, 9)},
+		{name: "offset table beyond trailer", data: testBinaryPlistData([]byte{0x09}, []byte{8}, 1, 1, 2, 0, 9)},
+		{name: "top object out of range", data: testBinaryPlistData([]byte{0x09}, []byte{8}, 1, 1, 1, 1, 9)},
+		{name: "object offset in offset table", data: testBinaryPlistData([]byte{0x09}, []byte{9}, 1, 1, 1, 0, 9)},
+		{name: "unknown object type", data: testBinaryPlistData([]byte{0x70}, []byte{8}, 1, 1, 1, 0, 9)},
+		{name: "string beyond objects", data: testBinaryPlistData([]byte{0x55, 'a'}, []byte{8}, 1, 1, 1, 0, 10)},
+		{name: "huge length", data: testBinaryPlistData([]byte{0x5F, 0x13, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, []byte{8}, 1, 1, 1, 0, 18)},
+		{name: "cyclic array", data: testBinaryPlistData([]byte{0xA1, 0x00}, []byte{8}, 1, 1, 1, 0, 10)},
+		{name: "non-string key", data: testBinaryPlistData([]byte{0xD1, 0x01, 0x01, 0x09}, []byte{8, 11}, 1, 1, 2, 0, 12)},
+		{name: "too many objects", data: testBinaryPlistData([]byte{0x09}, []byte{8}, 1, 1, maxBinaryPlistObjects+1, 0, 9)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := parseBinaryPlist(tt.data)
+			if err == nil {
+				t.Errorf("parseBinaryPlist() = %#v, want error", value)
+			}
+		})
+	}
+}
+
+func TestParseBinaryPlistShared(t *testing.T) {
+	const depth = 64
+
+	// Every array references the next one twice, which expands to 2^64 arrays without parsing shared objects once
+	var objects, offsets []byte
+
+	for i := range depth {
+		offsets = append(offsets, byte(len(binaryPlistMagic)+len(objects)))
+		objects = append(objects, 0xA2, byte(i+1), byte(i+1))
+	}
+
+	offsets = append(offsets, byte(len(binaryPlistMagic)+len(objects)))
+	objects = append(objects, 0x09)
+
+	value, err := parseBinaryPlist(testBinaryPlistData(objects, offsets, 1, 1, depth+1, 0, uint64(len(binaryPlistMagic)+len(objects))))
+	if err != nil {
+		t.Fatalf("parseBinaryPlist() = %v", err)
+	}
+
+	for i := range depth {
+		array, ok := value.([]any)
+		if !ok || len(array) != 2 {
+			t.Fatalf("array [%d] = %#v, want 2 elements", i, value)
+		}
+
+		value = array[1]
+	}
+
+	if value != true {
+		t.Errorf("innermost value = %#v, want true", value)
+	}
+}
+
+func TestParseXMLPlist(t *testing.T) {
+	tests := []struct {
+		name    string // name is the name of the test.
+		data    string // data is the property list.
+		want    any    // want is the expected value.
+		wantErr bool   // wantErr is true if parsing is expected to fail.
+	}{
+		{
+			name: "all types",
+			data: `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>String</key><string>Ex &amp; Co</string>
+	<key>Int</key><integer>-5</integer>
+	<key>Real</key><real>1.5</real>
+	<key>True</key><true/>
+	<key>False</key><false/>
+	<key>Data</key><data>AAEC</data>
+	<key>Date</key><date>2024-01-02T03:04:05Z</date>
+	<key>Array</key><array><integer>1</integer><string>two</string><array/></array>
+	<key>Nested</key><dict><key>k</key><string>v</string></dict>
+</dict>
+</plist>`,
+			want: map[string]any{
+				"String": "Ex & Co",
+				"Int":    int64(-5),
+				"Real":   1.5,
+				"True":   true,
+				"False":  false,
+				"Data":   []byte{0, 1, 2},
+				"Date":   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				"Array":  []any{int64(1), "two", []any{}},
+				"Nested": map[string]any{"k": "v"},
+			},
+		},
+		{name: "top-level array", data: `<plist version="1.0"><array><true/></array></plist>`, want: []any{true}},
+		{name: "empty plist", data: `<plist version="1.0"></plist>`, wantErr: true},
+		{name: "other root element", data: `<html></html>`, wantErr: true},
+		{name: "invalid integer", data: `<plist version="1.0"><integer>five</integer></plist>`, wantErr: true},
+		{name: "truncated", data: `<plist version="1.0"><dict><key>k</key>`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseXMLPlist([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseXMLPlist() = %#v, want error", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseXMLPlist() = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseXMLPlist() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package decrypt
+
+import (
+	"fmt"
+)
+
+// Progress receives progress updates while dumping an application. Dumping happens in consecutive steps, each of
+// which is started and stopped exactly once.
+type Progress interface {
+	// Start starts a new step. Total is the amount of work of the step (e.g. bytes), or zero if unknown.
+	Start(title string, total int64)
+
+	// Add reports n units of completed work for the current step.
+	Add(n int64)
+
+	// UpdateTitle changes the title of the current step.
+	UpdateTitle(title string)
+
+	// Stop stops the current step.
+	Stop()
+}
+
+// nopProgress is a progress that ignores all updates.
+type nopProgress struct{}
+
+func (nopProgress) Start(string, int64) {}
+func (nopProgress) Add(int64)           {}
+func (nopProgress) UpdateTitle(string)  {}
+func (nopProgress) Stop()               {}
+
+// transferProgress reports the bytes and files of a transfer as progress. Bytes are reported by writing to it.
+type transferProgress struct {
+	progress   Progress // progress receives the updates.
+	title      string   // title of the transfer step.
+	files      int      // files is the number of files transferred so far.
+	totalFiles int      // totalFiles is the expected number of files, or zero if unknown.
+}
+
+// startTransferProgress starts a transfer step with the given totals.
+func startTransferProgress(progress Progress, title string, totalBytes int64, totalFiles int) *transferProgress {
+	tp := &transferProgress{progress: progress, title: title, totalFiles: totalFiles}
+	progress.Start(tp.currentTitle(), totalBytes)
+
+	return tp
+}
+
+// Write reports len(p) transferred bytes.
+func (tp *transferProgress) Write(p []byte) (int, error) {
+	tp.progress.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// fileDone reports a transferred file.
+func (tp *transferProgress) fileDone() {
+	tp.files++
+	tp.progress.UpdateTitle(tp.currentTitle())
+}
+
+// currentTitle returns the title including the file count.
+func (tp *transferProgress) currentTitle() string {
+	if tp.totalFiles == 0 {
+		return fmt.Sprintf("%s (%d files)", tp.title, tp.files)
+	}
+
+	return fmt.Sprintf("%s (%d/%d files)", tp.title, tp.files, tp.totalFiles)
+}
@@ -0,0 +1,211 @@
+package decrypt
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The scripts are written in TypeScript ("scripts/src/*.ts"), and compiled into the embedded "scripts/*.js", which are
+// committed, so building the Go code doesn't require Node.js. Regenerate them after changing the sources. During
+// development, "npm run watch" in "scripts" keeps them up to date, and --scripts-dir loads them without rebuilding.
+//
+//go:generate npm --prefix scripts install
+//go:generate npm --prefix scripts run build
+
+//go:embed scripts/*.js
+var scriptsFS embed.FS
+
+var (
+	// preludeScript is the script with the RPC helpers, which is prepended to all other scripts.
+	preludeScript = "rpc.js"
+
+	// requiredScripts are the scripts that must be available in every script registry.
+	requiredScripts = []string{preludeScript, "dump.js", "filesystem.js", "runningboardd.js"}
+
+	// scriptVersionRegexp matches the "@version" tag in the header of a script.
+	scriptVersionRegexp = regexp.MustCompile(`@version\s+(\S+)`)
+)
+
+// ScriptInfo describes a script in the script registry.
+type ScriptInfo struct {
+	Name    string // Name is the file name of the script (e.g. "dump.js").
+	Version string // Version is taken from the "@version" tag of the script ("(undefined)" if missing).
+	Hash    string // Hash is the hex encoded SHA-256 hash of the script content.
+	Source  string // Source is "embedded", or the directory the script was read from.
+}
+
+// ScriptRegistry holds the Frida scripts loaded into device processes.
+type ScriptRegistry struct {
+	fsys    fs.FS                  // fsys is the filesystem the scripts are read from.
+	source  string                 // source is "embedded", or the directory the scripts are read from.
+	scripts map[string]*ScriptInfo // scripts are all available scripts, by name.
+}
+
+var (
+	scriptRegistryMu sync.RWMutex    // scriptRegistryMu guards scriptRegistry.
+	scriptRegistry   *ScriptRegistry // scriptRegistry is the registry scripts are read from (embedded if nil).
+)
+
+// NewScriptRegistry creates a script registry. Scripts are read from the given directory, or from the scripts
+// embedded into the binary if the directory is empty. All required scripts must be present.
+func NewScriptRegistry(dir string) (*ScriptRegistry, error) {
+	reg := &ScriptRegistry{source: "embedded", scripts: make(map[string]*ScriptInfo)}
+
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("open scripts directory: %w", err)
+		}
+
+		if !info.IsDir() {
+			return nil, fmt.Errorf("open scripts directory: not a directory [%s]", dir)
+		}
+
+		reg.fsys = os.DirFS(dir)
+		reg.source = dir
+	} else {
+		sub, err := fs.Sub(scriptsFS, "scripts")
+		if err != nil {
+			return nil, fmt.Errorf("open embedded scripts: %w", err)
+		}
+
+		reg.fsys = sub
+	}
+
+	// Enumerate scripts
+	matches, err := fs.Glob(reg.fsys, "*.js")
+	if err != nil {
+		return nil, fmt.Errorf("enumerate scripts [%s]: %w", reg.source, err)
+	}
+
+	for _, name := range matches {
+		content, err := fs.ReadFile(reg.fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("read script [%s]: %w", name, err)
+		}
+
+		hash := sha256.Sum256(content)
+		version := "(undefined)"
+
+		if m := scriptVersionRegexp.FindSubmatch(content); m != nil {
+			version = string(m[1])
+		}
+
+		reg.scripts[name] = &ScriptInfo{Name: name, Version: version, Hash: hex.EncodeToString(hash[:]), Source: reg.source}
+	}
+
+	// Validate required scripts
+	var missing []string
+
+	for _, name := range requiredScripts {
+		if _, ok := reg.scripts[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing scripts [%s]: %s", reg.source, strings.Join(missing, ", "))
+	}
+
+	return reg, nil
+}
+
+// UseScriptsDir validates the scripts in the given directory and makes them replace the embedded scripts for all
+// subsequently loaded scripts. The embedded scripts are validated and used if the directory is empty.
+func UseScriptsDir(dir string) error {
+	reg, err := NewScriptRegistry(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range reg.Scripts() {
+		slog.Debug("Registered script", slog.String("name", info.Name), slog.String("version", info.Version),
+			slog.String("source", info.Source))
+	}
+
+	scriptRegistryMu.Lock()
+	defer scriptRegistryMu.Unlock()
+
+	scriptRegistry = reg
+
+	return nil
+}
+
+// Scripts returns all scripts in the registry, sorted by name.
+func (reg *ScriptRegistry) Scripts() []ScriptInfo {
+	infos := make([]ScriptInfo, 0, len(reg.scripts))
+
+	for _, info := range reg.scripts {
+		infos = append(infos, *info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos
+}
+
+// Read reads a script, prefixed with the RPC helpers shared by all scripts. Reading the script with the RPC helpers
+// itself returns it without prefix.
+func (reg *ScriptRegistry) Read(name string) (string, error) {
+	if _, ok := reg.scripts[name]; !ok {
+		return "", fmt.Errorf("unknown script [%s]", name)
+	}
+
+	prelude, err := fs.ReadFile(reg.fsys, preludeScript)
+	if err != nil {
+		return "", fmt.Errorf("read script [%s]: %w", preludeScript, err)
+	}
+
+	if name == preludeScript {
+		return string(prelude), nil
+	}
+
+	content, err := fs.ReadFile(reg.fsys, name)
+	if err != nil {
+		return "", fmt.Errorf("read script [%s]: %w", name, err)
+	}
+
+	return string(prelude) + "\n" + string(content), nil
+}
+
+// currentScriptRegistry returns the registry scripts are read from, falling back to the embedded scripts.
+func currentScriptRegistry() (*ScriptRegistry, error) {
+	scriptRegistryMu.RLock()
+	reg := scriptRegistry
+	scriptRegistryMu.RUnlock()
+
+	if reg != nil {
+		return reg, nil
+	}
+
+	return NewScriptRegistry("")
+}
+
+// ScriptVersions returns all scripts of the registry in use, sorted by name.
+func ScriptVersions() ([]ScriptInfo, error) {
+	reg, err := currentScriptRegistry()
+	if err != nil {
+		return nil, err
+	}
+
+	return reg.Scripts(), nil
+}
+
+// readScript reads a script from the registry in use, prefixed with the RPC helpers shared by all scripts.
+func readScript(name string) (string, error) {
+	reg, err := currentScriptRegistry()
+	if err != nil {
+		return "", err
+	}
+
+	return reg.Read(name)
+}
@@ -0,0 +1,266 @@
+package decrypt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testScripts are scripts with all required names.
+var testScripts = map[string]string{
+	"rpc.js":           "/**\n * @version 2.0.0\n */\nrpc();",
+	"dump.js":          "/**\n * @version 2.1.0\n */\ndump();",
+	"filesystem.js":    "filesystem();",
+	"runningboardd.js": "runningboardd();",
+}
+
+// testScriptsDir creates a scripts directory with the given scripts.
+func testScriptsDir(t *testing.T, scripts map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range scripts {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("write script [%s]: %v", name, err)
+		}
+	}
+
+	return dir
+}
+
+// testWithout returns a copy of scripts without the given script, and with additional ones.
+func testWithout(scripts map[string]string, name string, additional map[string]string) map[string]string {
+	result := make(map[string]string)
+
+	for n, content := range scripts {
+		if n != name {
+			result[n] = content
+		}
+	}
+
+	for n, content := range additional {
+		result[n] = content
+	}
+
+	return result
+}
+
+// testEmbeddedScript reads a script embedded into the binary.
+func testEmbeddedScript(t *testing.T, name string) string {
+	t.Helper()
+
+	content, err := scriptsFS.ReadFile("scripts/" + name)
+	if err != nil {
+		t.Fatalf("read embedded script [%s]: %v", name, err)
+	}
+
+	return string(content)
+}
+
+func TestNewScriptRegistry(t *testing.T) {
+	tests := []struct {
+		name         string            // name is the name of the test.
+		scripts      map[string]string // scripts are written to the scripts directory (nil for embedded scripts).
+		dir          string            // dir is the scripts directory, if no scripts are written.
+		wantScripts  []string          // wantScripts are the names of the expected scripts.
+		wantVersions map[string]string // wantVersions are the expected versions of some scripts, by name.
+		wantErr      bool              // wantErr is true if creating the registry is expected to fail.
+	}{
+		{
+			name:        "embedded",
+			wantScripts: []string{"dump.js", "filesystem.js", "rpc.js", "runningboardd.js"},
+		},
+		{
+			name:         "scripts directory",
+			scripts:      testWithout(testScripts, "", map[string]string{"extra.js": "extra();", "README.md": "# Scripts"}),
+			wantScripts:  []string{"dump.js", "extra.js", "filesystem.js", "rpc.js", "runningboardd.js"},
+			wantVersions: map[string]string{"rpc.js": "2.0.0", "dump.js": "2.1.0", "filesystem.js": "(undefined)"},
+		},
+		{
+			name:    "missing prelude",
+			scripts: testWithout(testScripts, "rpc.js", nil),
+			wantErr: true,
+		},
+		{
+			name:    "missing script",
+			scripts: testWithout(testScripts, "dump.js", nil),
+			wantErr: true,
+		},
+		{
+			name:    "missing directory",
+			dir:     filepath.Join(os.TempDir(), "decrypt-missing-scripts"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tt.dir
+			if tt.scripts != nil {
+				dir = testScriptsDir(t, tt.scripts)
+			}
+
+			reg, err := NewScriptRegistry(dir)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewScriptRegistry() succeeded, want error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewScriptRegistry() = %v", err)
+			}
+
+			wantSource := "embedded"
+			if dir != "" {
+				wantSource = dir
+			}
+
+			var names []string
+
+			for _, info := range reg.Scripts() {
+				names = append(names, info.Name)
+
+				if info.Source != wantSource {
+					t.Errorf("script [%s] source = %q, want %q", info.Name, info.Source, wantSource)
+				}
+
+				if want, ok := tt.wantVersions[info.Name]; ok && info.Version != want {
+					t.Errorf("script [%s] version = %q, want %q", info.Name, info.Version, want)
+				}
+			}
+
+			if strings.Join(names, ",") != strings.Join(tt.wantScripts, ",") {
+				t.Errorf("Scripts() = %q, want %q", names, tt.wantScripts)
+			}
+		})
+	}
+}
+
+func TestNewScriptRegistryNotADirectory(t *testing.T) {
+	dir := testScriptsDir(t, testScripts)
+
+	_, err := NewScriptRegistry(filepath.Join(dir, "rpc.js"))
+	if err == nil {
+		t.Errorf("NewScriptRegistry() succeeded, want error")
+	}
+}
+
+func TestScriptRegistryRead(t *testing.T) {
+	embedded, err := NewScriptRegistry("")
+	if err != nil {
+		t.Fatalf("NewScriptRegistry() = %v", err)
+	}
+
+	override, err := NewScriptRegistry(testScriptsDir(t, testScripts))
+	if err != nil {
+		t.Fatalf("NewScriptRegistry() = %v", err)
+	}
+
+	tests := []struct {
+		name    string          // name is the name of the test.
+		reg     *ScriptRegistry // reg is the registry the script is read from.
+		script  string          // script is the name of the read script.
+		want    string          // want is the expected content.
+		wantErr bool            // wantErr is true if reading is expected to fail.
+	}{
+		{
+			name:   "embedded",
+			reg:    embedded,
+			script: "dump.js",
+			want:   testEmbeddedScript(t, "rpc.js") + "\n" + testEmbeddedScript(t, "dump.js"),
+		},
+		{
+			name:   "embedded prelude",
+			reg:    embedded,
+			script: "rpc.js",
+			want:   testEmbeddedScript(t, "rpc.js"),
+		},
+		{
+			name:   "scripts directory",
+			reg:    override,
+			script: "dump.js",
+			want:   testScripts["rpc.js"] + "\n" + testScripts["dump.js"],
+		},
+		{
+			name:   "scripts directory prelude",
+			reg:    override,
+			script: "rpc.js",
+			want:   testScripts["rpc.js"],
+		},
+		{
+			name:    "unknown script",
+			reg:     override,
+			script:  "missing.js",
+			wantErr: true,
+		},
+		{
+			name:    "not a script",
+			reg:     override,
+			script:  "../registry.go",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.reg.Read(tt.script)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() = %v, want error %t", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseScriptsDir(t *testing.T) {
+	t.Cleanup(func() {
+		scriptRegistryMu.Lock()
+		defer scriptRegistryMu.Unlock()
+
+		scriptRegistry = nil
+	})
+
+	// Scripts directory replaces the embedded scripts
+	err := UseScriptsDir(testScriptsDir(t, testScripts))
+	if err != nil {
+		t.Fatalf("UseScriptsDir() = %v", err)
+	}
+
+	got, err := readScript("dump.js")
+	if err != nil {
+		t.Fatalf("readScript() = %v", err)
+	}
+
+	if want := testScripts["rpc.js"] + "\n" + testScripts["dump.js"]; got != want {
+		t.Errorf("readScript() = %q, want %q", got, want)
+	}
+
+	// Invalid scripts directory keeps the scripts in use
+	err = UseScriptsDir(testScriptsDir(t, testWithout(testScripts, "rpc.js", nil)))
+	if err == nil {
+		t.Fatalf("UseScriptsDir() succeeded, want error")
+	}
+
+	if got, err := readScript("dump.js"); err != nil || !strings.HasSuffix(got, testScripts["dump.js"]) {
+		t.Errorf("readScript() = %q, %v, want script from scripts directory", got, err)
+	}
+
+	// Empty directory switches back to the embedded scripts
+	err = UseScriptsDir("")
+	if err != nil {
+		t.Fatalf("UseScriptsDir() = %v", err)
+	}
+
+	if got, err := readScript("dump.js"); err != nil || !strings.HasSuffix(got, testEmbeddedScript(t, "dump.js")) {
+		t.Errorf("readScript() = %q, %v, want embedded script", got, err)
+	}
+}
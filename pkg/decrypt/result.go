@@ -0,0 +1,72 @@
+package decrypt
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// BinaryResult describes a single encrypted binary of a dumped application.
+type BinaryResult struct {
+	Path              string `json:"path"`                // Path is the path of the binary, relative to the app bundle.
+	FileType          string `json:"fileType"`            // FileType is the Mach-O file type (e.g. "executable").
+	Slice             string `json:"slice"`               // Slice is the architecture of the binary (e.g. "arm64").
+	Extension         string `json:"extension,omitempty"` // Extension is the ID of the owning extension (empty for the app).
+	CryptIDBefore     uint32 `json:"cryptIdBefore"`       // CryptIDBefore is the cryptid of the binary before dumping.
+	CryptIDAfter      uint32 `json:"cryptIdAfter"`        // CryptIDAfter is the cryptid of the binary after dumping.
+	BytesDecrypted    int64  `json:"bytesDecrypted"`      // BytesDecrypted is the number of bytes replaced from memory.
+	SignatureStripped bool   `json:"signatureStripped"`   // SignatureStripped is true if the code signature has been stripped.
+	CDHash            string `json:"cdhash,omitempty"`    // CDHash is the hex encoded CDHash of the ad-hoc signature, if signed.
+}
+
+// Decrypted returns true if the binary has been decrypted.
+func (b BinaryResult) Decrypted() bool {
+	return b.CryptIDAfter == 0
+}
+
+// DumpTimings holds the durations of the phases of a dump.
+type DumpTimings struct {
+	Pull    time.Duration `json:"pull"`    // Pull is the time spent pulling the app bundle from the device.
+	Decrypt time.Duration `json:"decrypt"` // Decrypt is the time spent decrypting binaries.
+	Package time.Duration `json:"package"` // Package is the time spent packaging the IPA.
+	Total   time.Duration `json:"total"`   // Total is the time spent on the whole dump.
+}
+
+// DumpResult describes the outcome of dumping an application.
+type DumpResult struct {
+	Identifier    string         `json:"identifier"`              // Identifier is the bundle identifier of the application.
+	Version       string         `json:"version"`                 // Version is the version of the application.
+	Build         string         `json:"build"`                   // Build is the build number of the application.
+	Output        string         `json:"output,omitempty"`        // Output is the path of the IPA (or of the cached one).
+	SigningReport string         `json:"signingReport,omitempty"` // SigningReport is the path of the signing report, if written.
+	Binaries      []BinaryResult `json:"binaries"`                // Binaries are all encrypted binaries found in the app bundle.
+	Started       time.Time      `json:"started"`                 // Started is the time dumping was started.
+	Timings       DumpTimings    `json:"timings"`                 // Timings are the durations of the phases of the dump.
+	Warnings      []string       `json:"warnings,omitempty"`      // Warnings are issues that didn't prevent dumping.
+}
+
+// newDumpResult creates a result for dumping the application.
+func newDumpResult(app *Application) *DumpResult {
+	return &DumpResult{
+		Identifier: app.Identifier,
+		Version:    app.Version,
+		Build:      app.Build,
+		Binaries:   []BinaryResult{},
+		Started:    time.Now(),
+	}
+}
+
+// warn logs a warning, and records it in the result.
+func (res *DumpResult) warn(msg string, args ...any) {
+	slog.Warn(msg, args...)
+
+	record := slog.NewRecord(time.Time{}, slog.LevelWarn, msg, 0)
+	record.Add(args...)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		msg += fmt.Sprintf(" [%s=%s]", attr.Key, attr.Value)
+		return true
+	})
+
+	res.Warnings = append(res.Warnings, msg)
+}
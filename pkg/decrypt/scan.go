@@ -0,0 +1,106 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// ScanResult describes an app bundle scanned without pulling it.
+type ScanResult struct {
+	Size     int64        // Size is the total size of all files in the bundle in bytes.
+	Files    int          // Files is the number of files in the bundle.
+	Binaries []*MachOInfo // Binaries are all Mach-O binaries in the bundle, encrypted or not.
+}
+
+// Encrypted returns the encrypted binaries of the bundle.
+func (s *ScanResult) Encrypted() []*MachOInfo {
+	var binaries []*MachOInfo
+
+	for _, binary := range s.Binaries {
+		if binary.CryptID != 0 {
+			binaries = append(binaries, binary)
+		}
+	}
+
+	return binaries
+}
+
+// Unsupported returns the encrypted binaries that require a newer OS than osVersion. These can't be loaded on the
+// device, so they are left encrypted.
+func (s *ScanResult) Unsupported(osVersion string) []*MachOInfo {
+	var binaries []*MachOInfo
+
+	for _, binary := range s.Encrypted() {
+		if !binary.SupportedOn(osVersion) {
+			binaries = append(binaries, binary)
+		}
+	}
+
+	return binaries
+}
+
+// Scan scans the app bundle on the device via SFTP, without pulling it. Only the headers and load commands of Mach-O
+// binaries are read, so this is fast even for large bundles.
+func (d *Dumper) Scan(ctx context.Context, app *Application) (*ScanResult, error) {
+	// Establish SSH and SFTP connections
+	err := d.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.sftp == nil {
+		return nil, fmt.Errorf("SFTP subsystem not available")
+	}
+
+	// Close the connection on cancellation, which aborts all pending operations
+	stop := context.AfterFunc(ctx, func() { d.ssh.Close() })
+	defer stop()
+
+	// Scan app bundle
+	progress := d.opts.Progress
+
+	progress.Start("Scanning app bundle", 0)
+	defer progress.Stop()
+
+	scan, err := scanBundle(SFTPFS(d.sftp, app.Path), false)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	return scan, nil
+}
+
+// scanBeforePull scans the app bundle on the device, and warns about problems before the bundle is pulled. It returns
+// nil if scanning is disabled or the bundle can't be scanned, which doesn't prevent dumping.
+func (d *Dumper) scanBeforePull(ctx context.Context, app *Application) *ScanResult {
+	// Scanning requires SFTP
+	if d.opts.NoScan || d.opts.Transfer == TransferFrida {
+		return nil
+	}
+
+	scan, err := d.Scan(ctx, app)
+	if err != nil {
+		slog.Debug("Failed to scan app bundle on device", slog.Any("error", err))
+		return nil
+	}
+
+	slog.Info("Scanned app bundle",
+		slog.Int64("size", scan.Size),
+		slog.Int("files", scan.Files),
+		slog.Int("binaries", len(scan.Binaries)),
+		slog.Int("encrypted", len(scan.Encrypted())))
+
+	for _, binary := range scan.Unsupported(app.device.OSVersion) {
+		slog.Warn("Binary requires a newer OS version than the device runs, and will be left encrypted",
+			slog.String("path", binary.Path),
+			slog.String("minOS", binary.MinOS),
+			slog.String("osVersion", app.device.OSVersion))
+	}
+
+	return scan
+}
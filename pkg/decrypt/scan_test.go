@@ -0,0 +1,43 @@
+package decrypt
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScanResultUnsupported(t *testing.T) {
+	scan := &ScanResult{
+		Binaries: []*MachOInfo{
+			{Path: "Ex", CryptID: 1, MinOS: "15.0"},
+			{Path: "Frameworks/A.framework/A", CryptID: 1, MinOS: "17.0.1"},
+			{Path: "Frameworks/B.dylib", CryptID: 0, MinOS: "18.0"},
+			{Path: "Frameworks/C.dylib", CryptID: 1, MinOS: ""},
+			{Path: "PlugIns/W.appex/W", CryptID: 1, MinOS: "16.4"},
+		},
+	}
+
+	tests := []struct {
+		name      string   // name is the name of the test.
+		osVersion string   // osVersion is the OS version of the device.
+		want      []string // want are the paths of the expected unsupported binaries.
+	}{
+		{name: "old device", osVersion: "14.8", want: []string{"Ex", "Frameworks/A.framework/A", "PlugIns/W.appex/W"}},
+		{name: "same version", osVersion: "16.4", want: []string{"Frameworks/A.framework/A"}},
+		{name: "patch version", osVersion: "17.0", want: []string{"Frameworks/A.framework/A"}},
+		{name: "new device", osVersion: "17.0.1", want: nil},
+		{name: "unknown version", osVersion: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, binary := range scan.Unsupported(tt.osVersion) {
+				got = append(got, binary.Path)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Unsupported(%q) = %q, want %q", tt.osVersion, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package decrypt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/frida/frida-go/frida"
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// MessageHandler handles a custom message sent by a script via send(). It receives the message payload and the
+// optional binary data attached to it.
+type MessageHandler func(payload map[string]any, data []byte)
+
+// subscription is a registered message handler.
+type subscription struct {
+	handler MessageHandler // handler of the subscription.
+}
+
+// scriptLogLevels maps console log levels of scripts to slog levels.
+var scriptLogLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"warning": slog.LevelWarn,
+	"error":   slog.LevelError,
+}
+
+// Script represents a Frida script loaded into a process.
+type Script struct {
+	session *frida.Session // The Frida process session.
+	script  *frida.Script  // The loaded script.
+	logger  *slog.Logger   // Logger for console output and errors, with process and script name attached.
+
+	handlersMu sync.Mutex               // Guards handlers.
+	handlers   map[string]*subscription // Message handlers by message type.
+}
+
+// LoadScriptIntoProcess loads a Frida script into a specified process on the device. The name identifies the
+// script in log messages.
+func (dev *Device) LoadScriptIntoProcess(ctx context.Context, name string, content string, processName string) (*Script, error) {
+	return dev.loadScript(ctx, name, content, processName)
+}
+
+// LoadScriptIntoPID loads a Frida script into the process with the specified PID on the device. The name identifies
+// the script in log messages.
+func (dev *Device) LoadScriptIntoPID(ctx context.Context, name string, content string, pid int) (*Script, error) {
+	return dev.loadScript(ctx, name, content, pid)
+}
+
+// loadScript loads a Frida script into a process on the device, identified either by name or by PID.
+func (dev *Device) loadScript(ctx context.Context, name string, content string, process any) (*Script, error) {
+	// Attach to process
+	session, err := dev.device.AttachWithContext(ctx, process, nil)
+	if err != nil {
+		return nil, fmt.Errorf("attach to process [%v]: %w", process, err)
+	}
+
+	// Create script
+	script, err := session.CreateScriptWithOptions(content, frida.NewScriptOptions(name))
+	if err != nil {
+		session.Detach() //nolint
+		return nil, fmt.Errorf("create script: %w", err)
+	}
+
+	scr := &Script{
+		session:  session,
+		script:   script,
+		logger:   slog.With(slog.String("process", fmt.Sprint(process)), slog.String("script", name)),
+		handlers: make(map[string]*subscription),
+	}
+
+	// Handle messages (must be registered before loading)
+	script.On("message", scr.onMessage)
+
+	// Load script into process
+	if err := script.Load(); err != nil {
+		session.Detach() //nolint
+		return nil, fmt.Errorf("load script: %w", err)
+	}
+
+	return scr, nil
+}
+
+// Close cleans up script and session resources.
+func (scr *Script) Close() {
+	scr.script.Unload()  //nolint
+	scr.session.Detach() //nolint
+}
+
+// Call invokes an exported function from the loaded script with the provided arguments. Errors reported by the
+// script are returned as *ScriptError. It returns the context's error if the context is done before the call returns.
+func (scr *Script) Call(ctx context.Context, fn string, args ...any) (any, error) {
+	ret := scr.script.ExportsCallWithContext(ctx, fn, args...)
+	return decodeCallResult(ctx, fn, ret)
+}
+
+// decodeCallResult unwraps the value returned by a call of the exported function fn. Exports return an envelope with
+// either the result or the error of the call.
+func decodeCallResult(ctx context.Context, fn string, ret any) (any, error) {
+	// Calls abandoned because the context is done return frida.ErrContextCancelled instead of the context's error
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err, ok := ret.(error); ok {
+		return nil, fmt.Errorf("call [%s]: %w", fn, err)
+	}
+
+	// Failed RPC calls (e.g. unknown exports) are only reported by their message
+	if _, ok := ret.(map[string]any); !ok {
+		return nil, &ScriptError{Function: fn, Code: "rpc-error", Message: fmt.Sprint(ret)}
+	}
+
+	// Unwrap envelope
+	var envelope struct {
+		Result any          `mapstructure:"result"`
+		Error  *ScriptError `mapstructure:"error"`
+	}
+
+	err := mapstructure.Decode(ret, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+
+	if envelope.Error != nil {
+		envelope.Error.Function = fn
+		return nil, envelope.Error
+	}
+
+	return envelope.Result, nil
+}
+
+// CallAs invokes an exported function from the loaded script, just like Script.Call, and decodes the result into T.
+func CallAs[T any](ctx context.Context, scr *Script, fn string, args ...any) (T, error) {
+	var result T
+
+	ret, err := scr.Call(ctx, fn, args...)
+	if err != nil {
+		return result, err
+	}
+
+	err = mapstructure.Decode(ret, &result)
+	if err != nil {
+		return result, fmt.Errorf("decode result of [%s]: %w", fn, err)
+	}
+
+	return result, nil
+}
+
+// Subscribe registers a handler for messages sent by the script via send() whose payload has the given "type"
+// field. A previously registered handler for the same type is replaced. The returned function unsubscribes the
+// handler again.
+func (scr *Script) Subscribe(messageType string, handler MessageHandler) func() {
+	sub := &subscription{handler: handler}
+
+	scr.handlersMu.Lock()
+	defer scr.handlersMu.Unlock()
+
+	scr.handlers[messageType] = sub
+
+	return func() {
+		scr.handlersMu.Lock()
+		defer scr.handlersMu.Unlock()
+
+		if scr.handlers[messageType] == sub {
+			delete(scr.handlers, messageType)
+		}
+	}
+}
+
+// onMessage handles messages received from the script. Console output and uncaught errors are logged, and custom
+// messages are dispatched to the subscribed handlers.
+func (scr *Script) onMessage(message string, data []byte) {
+	// Decode message
+	var msg struct {
+		Type         string          `json:"type"`
+		Level        string          `json:"level"`
+		Payload      json.RawMessage `json:"payload"`
+		Description  string          `json:"description"`
+		Stack        string          `json:"stack"`
+		FileName     string          `json:"fileName"`
+		LineNumber   int             `json:"lineNumber"`
+		ColumnNumber int             `json:"columnNumber"`
+	}
+
+	err := json.Unmarshal([]byte(message), &msg)
+	if err != nil {
+		scr.logger.Warn("Failed to decode script message", slog.Any("error", err))
+		return
+	}
+
+	switch msg.Type {
+	case "log":
+		// Log console output
+		var text string
+		json.Unmarshal(msg.Payload, &text) //nolint:errcheck
+
+		scr.logger.Log(context.Background(), scriptLogLevels[msg.Level], text)
+
+	case "error":
+		// Log uncaught error
+		scr.logger.Error(
+			"Uncaught script error",
+			slog.String("description", msg.Description),
+			slog.String("location", fmt.Sprintf("%s:%d:%d", msg.FileName, msg.LineNumber, msg.ColumnNumber)),
+			slog.String("stack", msg.Stack),
+		)
+
+	case "send":
+		// Find handler by message type
+		var payload map[string]any
+
+		err := json.Unmarshal(msg.Payload, &payload)
+		if err != nil {
+			scr.logger.Debug("Dropping script message without object payload", slog.String("payload", string(msg.Payload)))
+			return
+		}
+
+		messageType, _ := payload["type"].(string)
+
+		scr.handlersMu.Lock()
+		sub := scr.handlers[messageType]
+		scr.handlersMu.Unlock()
+
+		if sub == nil {
+			scr.logger.Debug("Dropping unhandled script message", slog.String("type", messageType))
+			return
+		}
+
+		sub.handler(payload, data)
+	}
+}
@@ -0,0 +1,255 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/frida/frida-go/frida"
+)
+
+// testRPCReply returns the value of a raw RPC reply message, just like Frida hands it to the caller.
+func testRPCReply(t *testing.T, message string) any {
+	t.Helper()
+
+	var msg struct {
+		Payload []any `json:"payload"`
+	}
+
+	err := json.Unmarshal([]byte(message), &msg)
+	if err != nil || len(msg.Payload) < 4 {
+		t.Fatalf("decode RPC reply [%s]: %v", message, err)
+	}
+
+	return msg.Payload[3]
+}
+
+func TestDecodeCallResult(t *testing.T) {
+	tests := []struct {
+		name     string // name is the name of the test.
+		message  string // message is the raw RPC reply.
+		want     any    // want is the expected result.
+		wantCode string // wantCode is the expected code of the script error (empty if the call succeeds).
+		wantErr  error  // wantErr is the sentinel error the script error is expected to match.
+	}{
+		{
+			name:    "result",
+			message: `{"type":"send","payload":["frida:rpc","1","ok",{"result":{"path":"/var/containers/Bundle/Application/A/Ex.app","size":42}}]}`,
+			want:    map[string]any{"path": "/var/containers/Bundle/Application/A/Ex.app", "size": float64(42)},
+		},
+		{
+			name:    "empty result",
+			message: `{"type":"send","payload":["frida:rpc","1","ok",{}]}`,
+			want:    nil,
+		},
+		{
+			name:     "error",
+			message:  `{"type":"send","payload":["frida:rpc","1","ok",{"error":{"code":"bundle-not-found","message":"no such bundle","stack":"Error: no such bundle\n    at main (/agent.js:1)"}}]}`,
+			wantCode: "bundle-not-found",
+			wantErr:  ErrBundleNotFound,
+		},
+		{
+			name:     "unknown error code",
+			message:  `{"type":"send","payload":["frida:rpc","1","ok",{"error":{"code":"out-of-cheese","message":"out of cheese"}}]}`,
+			wantCode: "out-of-cheese",
+		},
+		{
+			name:     "rpc error",
+			message:  `{"type":"send","payload":["frida:rpc","1","error","unable to find method 'missing'","Error","Error: unable to find method 'missing'"]}`,
+			wantCode: "rpc-error",
+		},
+		{
+			name:     "non-map result",
+			message:  `{"type":"send","payload":["frida:rpc","1","ok",42]}`,
+			wantCode: "rpc-error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCallResult(context.Background(), "lookup", testRPCReply(t, tt.message))
+
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("decodeCallResult() = %v", err)
+				}
+
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("decodeCallResult() = %#v, want %#v", got, tt.want)
+				}
+
+				return
+			}
+
+			var scriptErr *ScriptError
+			if !errors.As(err, &scriptErr) {
+				t.Fatalf("decodeCallResult() = %v, want *ScriptError", err)
+			}
+
+			if scriptErr.Code != tt.wantCode || scriptErr.Function != "lookup" {
+				t.Errorf("decodeCallResult() code = %q, function = %q, want %q, %q", scriptErr.Code, scriptErr.Function, tt.wantCode, "lookup")
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("decodeCallResult() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeCallResultContext(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	tests := []struct {
+		name    string          // name is the name of the test.
+		ctx     context.Context // ctx is the context of the call.
+		ret     any             // ret is the value returned by the call.
+		wantErr error           // wantErr is the expected error.
+	}{
+		{name: "cancelled", ctx: cancelled, ret: frida.ErrContextCancelled, wantErr: context.Canceled},
+		{name: "deadline exceeded", ctx: expired, ret: frida.ErrContextCancelled, wantErr: context.DeadlineExceeded},
+		{name: "cancelled after reply", ctx: cancelled, ret: map[string]any{"result": "ok"}, wantErr: context.Canceled},
+		{name: "error without cancellation", ctx: context.Background(), ret: frida.ErrContextCancelled, wantErr: frida.ErrContextCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCallResult(tt.ctx, "lookup", tt.ret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("decodeCallResult() = %v, want %v", err, tt.wantErr)
+			}
+
+			var scriptErr *ScriptError
+			if errors.As(err, &scriptErr) {
+				t.Errorf("decodeCallResult() = %v, want no *ScriptError", err)
+			}
+		})
+	}
+}
+
+func TestScriptErrorFormat(t *testing.T) {
+	err := &ScriptError{Function: "lookup", Code: "file-not-found", Message: "no such file", Stack: "Error: no such file"}
+
+	if got, want := err.Error(), "script call [lookup]: no such file [file-not-found]"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	if got, want := fmt.Sprintf("%+v", err), "script call [lookup]: no such file [file-not-found]\nError: no such file"; got != want {
+		t.Errorf("Format(%%+v) = %q, want %q", got, want)
+	}
+
+	if !errors.Is(err, ErrFileNotFound) || errors.Is(err, ErrBundleNotFound) {
+		t.Errorf("Is() doesn't match the sentinel error of the code")
+	}
+}
+
+// testScript returns a script without a Frida session, logging into buf.
+func testScript(buf *bytes.Buffer) *Script {
+	return &Script{
+		logger:   slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		handlers: make(map[string]*subscription),
+	}
+}
+
+func TestScriptOnMessage(t *testing.T) {
+	tests := []struct {
+		name     string // name is the name of the test.
+		message  string // message is the raw message sent by the script.
+		data     []byte // data is the binary data attached to the message.
+		wantType string // wantType is the type of the message expected to reach the handler (empty if none).
+		wantLog  string // wantLog is expected to be part of the log output.
+	}{
+		{
+			name:     "custom message",
+			message:  `{"type":"send","payload":{"type":"progress","done":1,"total":2}}`,
+			data:     []byte{0xCA, 0xFE},
+			wantType: "progress",
+		},
+		{
+			name:    "unhandled message",
+			message: `{"type":"send","payload":{"type":"unknown"}}`,
+			wantLog: "Dropping unhandled script message",
+		},
+		{
+			name:    "non-object payload",
+			message: `{"type":"send","payload":"hello"}`,
+			wantLog: "Dropping script message without object payload",
+		},
+		{
+			name:    "console output",
+			message: `{"type":"log","level":"warning","payload":"low on memory"}`,
+			wantLog: `level=WARN msg="low on memory"`,
+		},
+		{
+			name:    "uncaught error",
+			message: `{"type":"error","description":"TypeError: not a function","stack":"at main","fileName":"/agent.js","lineNumber":3,"columnNumber":7}`,
+			wantLog: `location=/agent.js:3:7`,
+		},
+		{
+			name:    "invalid message",
+			message: `{"type":`,
+			wantLog: "Failed to decode script message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			scr := testScript(&buf)
+
+			var gotType string
+			var gotData []byte
+
+			scr.Subscribe("progress", func(payload map[string]any, data []byte) {
+				gotType, _ = payload["type"].(string)
+				gotData = data
+			})
+
+			scr.onMessage(tt.message, tt.data)
+
+			if gotType != tt.wantType || (tt.wantType != "" && !bytes.Equal(gotData, tt.data)) {
+				t.Errorf("handler got type %q and data %x, want %q and %x", gotType, gotData, tt.wantType, tt.data)
+			}
+
+			if !strings.Contains(buf.String(), tt.wantLog) {
+				t.Errorf("log = %q, want %q", buf.String(), tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestScriptSubscribe(t *testing.T) {
+	var buf bytes.Buffer
+	scr := testScript(&buf)
+
+	message := `{"type":"send","payload":{"type":"progress"}}`
+
+	// Handlers are replaced by later subscriptions
+	var first, second int
+
+	unsubscribeFirst := scr.Subscribe("progress", func(map[string]any, []byte) { first++ })
+	unsubscribeSecond := scr.Subscribe("progress", func(map[string]any, []byte) { second++ })
+
+	scr.onMessage(message, nil)
+
+	// Unsubscribing a replaced handler keeps the current one
+	unsubscribeFirst()
+	scr.onMessage(message, nil)
+
+	unsubscribeSecond()
+	scr.onMessage(message, nil)
+
+	if first != 0 || second != 2 {
+		t.Errorf("handlers called %d and %d times, want 0 and 2", first, second)
+	}
+}
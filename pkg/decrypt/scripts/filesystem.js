@@ -0,0 +1,77 @@
+/**
+ * @file List and read files on the device
+ * @version 1.0.0
+ */
+
+/**
+ * List all files and directories below a directory
+ * @param {string} root path of the directory
+ * @returns {FileEntry[]} list of entries, with paths relative to the root
+ */
+exportFunction('list', function (root) {
+	requireObjC()
+
+	// Enumerate directory recursively
+	var entries = []
+
+	const enumerator = ObjC.classes.NSFileManager.defaultManager().enumeratorAtPath_(root)
+	if (!enumerator) {
+		throw new ScriptError('file-not-found', `directory "${root}" not found`)
+	}
+
+	let path
+
+	while ((path = enumerator.nextObject()) !== null) {
+		const attrs = enumerator.fileAttributes()
+
+		const type = attrs.objectForKey_('NSFileType').toString()
+		const size = attrs.objectForKey_('NSFileSize').doubleValue()
+		const mode = attrs.objectForKey_('NSFilePosixPermissions').unsignedShortValue()
+		const mtime = attrs.objectForKey_('NSFileModificationDate').timeIntervalSince1970()
+
+		entries.push({
+			path: path.toString(),
+			type: { NSFileTypeDirectory: 'directory', NSFileTypeRegular: 'file' }[type] || 'other',
+			size,
+			mode,
+			mtime,
+		})
+	}
+
+	return entries
+})
+
+/**
+ * Stream the content of a file as "chunk" messages
+ * @param {string} path path of the file
+ * @param {number} offset offset to start reading at
+ * @param {number} chunkSize maximum size of each chunk
+ * @returns {number} offset after the last chunk
+ */
+exportFunction('read', function (path, offset, chunkSize) {
+	let file
+
+	try {
+		file = new File(path, 'rb')
+	} catch (e) {
+		throw new ScriptError('file-not-found', `file "${path}" not found: ${e.message}`)
+	}
+
+	try {
+		file.seek(offset)
+
+		for (;;) {
+			const chunk = file.readBytes(chunkSize)
+			if (chunk.byteLength === 0) {
+				break
+			}
+
+			send({ type: 'chunk', path, offset }, chunk)
+			offset += chunk.byteLength
+		}
+	} finally {
+		file.close()
+	}
+
+	return offset
+})
@@ -0,0 +1,50 @@
+/**
+ * @file RPC helpers shared by all scripts
+ * @version 1.1.0
+ */
+
+/**
+ * Error reported to the caller with a machine-readable code
+ */
+class ScriptError extends Error {
+	/**
+	 * @param {string} code error code (e.g. "bundle-not-found")
+	 * @param {string} message human-readable message
+	 */
+	constructor(code, message) {
+		super(message)
+		this.code = code
+	}
+}
+
+/**
+ * Export a function via RPC. The result is wrapped into an envelope, so errors can be told apart from results.
+ * @param {string} name name of the export
+ * @param {Function} fn implementation of the export
+ */
+function exportFunction(name, fn) {
+	rpc.exports[name] = function (...args) {
+		try {
+			return { result: fn(...args) }
+		} catch (e) {
+			return { error: { code: e.code || 'script-error', message: e.message || String(e), stack: e.stack || '' } }
+		}
+	}
+}
+
+/**
+ * Ensure the Objective-C runtime is available
+ */
+function requireObjC() {
+	if (typeof ObjC === 'undefined' || !ObjC.available) {
+		throw new ScriptError('objc-unavailable', 'Objective-C runtime not available')
+	}
+}
+
+/**
+ * Get the version of the Frida runtime the script is running in
+ * @returns {string} Frida version
+ */
+exportFunction('version', function () {
+	return Frida.version
+})
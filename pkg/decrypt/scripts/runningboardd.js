@@ -0,0 +1,73 @@
+/**
+ * @file Look up applications and their extensions via runningboardd
+ * @version 1.0.0
+ */
+
+/*
+ * Get application proxy by bundle identifier
+ * @param {string} bundleId bundle id of the app
+ * @returns {ObjC.Object} application proxy object
+ */
+function getApp(bundleId) {
+	requireObjC()
+
+	// Get application proxy for the given bundle identifier.
+	const app = ObjC.classes.LSApplicationProxy.applicationProxyForIdentifier_(bundleId)
+	if (!app || !app.bundleURL()) {
+		throw new ScriptError('bundle-not-found', `bundle identifier "${bundleId}" not found`)
+	}
+
+	return app
+}
+
+/**
+ * Strip the "/private" prefix of a path, which is a symlink target on iOS
+ * @param {string} path path to normalize
+ * @returns {string} normalized path
+ */
+function normalizePath(path) {
+	return path.startsWith('/private/') ? path.substring('/private'.length) : path
+}
+
+/**
+ * Get list of extensions for the host app
+ * @param {string} bundleId bundle id of the app
+ * @returns {ExtensionInfo[]} list of extensions
+ */
+exportFunction('extensions', function (bundleId) {
+	const app = getApp(bundleId)
+	const appPath = normalizePath(app.bundleURL().path().toString())
+
+	// Iterate through plugins to find extensions
+	var extensions = []
+
+	const plugins = app.plugInKitPlugins()
+
+	for (let i = 0; i < plugins.count(); i++) {
+		const plugin = plugins.objectAtIndex_(i)
+
+		const id = plugin.bundleIdentifier().toString()
+		const absolutePath = normalizePath(plugin.bundleURL().path().toString())
+
+		const executable = plugin.infoPlist().objectForKey_('CFBundleExecutable')
+		if (!executable) {
+			throw new ScriptError('invalid-extension', `extension "${id}" has no executable`)
+		}
+
+		// Path relative to the app bundle, if the extension is part of it
+		const path = absolutePath.startsWith(appPath + '/') ? absolutePath.substring(appPath.length + 1) : absolutePath
+
+		extensions.push({ id, path, executable: executable.toString(), absolutePath: absolutePath + '/' + executable })
+	}
+
+	return extensions
+})
+
+/**
+ * Get main executable of the app
+ * @param {string} bundleId bundle id of the app
+ * @returns {string} path to the main executable
+ */
+exportFunction('main', function (bundleId) {
+	return getApp(bundleId).bundleExecutable().toString()
+})
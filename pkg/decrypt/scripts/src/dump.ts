@@ -0,0 +1,45 @@
+/**
+ * @file Dump decrypted binaries from the memory of an application
+ * @version 1.0.0
+ */
+
+/**
+ * Find a module by path, loading it if necessary
+ * @param path path of the module, relative to the bundle's parent directory
+ * @param absolutePath absolute path of the module
+ * @returns module
+ */
+function getModule(path: string, absolutePath: string): Module {
+	// Find module that has already been loaded
+	const module = Process.enumerateModules().find((m) => m.path.endsWith('/' + path))
+	if (module) {
+		return module
+	}
+
+	// Load module otherwise
+	try {
+		return Module.load(absolutePath)
+	} catch (e: any) {
+		throw new ScriptError('module-not-loaded', `module "${absolutePath}" could not be loaded: ${e.message}`)
+	}
+}
+
+/**
+ * Stream the decrypted range of a module as "chunk" messages
+ * @param path path of the module, relative to the bundle's parent directory
+ * @param absolutePath absolute path of the module
+ * @param offset offset of the encrypted range
+ * @param size size of the encrypted range
+ * @param chunkSize maximum size of each chunk
+ * @returns number of bytes dumped
+ */
+exportFunction('dump', function (path: string, absolutePath: string, offset: number, size: number, chunkSize: number): number {
+	const base = getModule(path, absolutePath).base.add(offset)
+
+	for (let pos = 0; pos < size; pos += chunkSize) {
+		const chunk = base.add(pos).readByteArray(Math.min(chunkSize, size - pos))
+		send({ type: 'chunk', path, offset: pos }, chunk)
+	}
+
+	return size
+})
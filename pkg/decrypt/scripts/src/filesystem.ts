@@ -0,0 +1,88 @@
+/**
+ * @file List and read files on the device
+ * @version 1.0.0
+ */
+
+/**
+ * Entry of a directory listing
+ */
+interface FileEntry {
+	path: string
+	type: 'directory' | 'file' | 'other'
+	size: number
+	mode: number
+	mtime: number
+}
+
+/**
+ * List all files and directories below a directory
+ * @param root path of the directory
+ * @returns list of entries, with paths relative to the root
+ */
+exportFunction('list', function (root: string): FileEntry[] {
+	requireObjC()
+
+	// Enumerate directory recursively
+	var entries: FileEntry[] = []
+
+	const enumerator = ObjC.classes.NSFileManager.defaultManager().enumeratorAtPath_(root)
+	if (!enumerator) {
+		throw new ScriptError('file-not-found', `directory "${root}" not found`)
+	}
+
+	let path: ObjC.Object | null
+
+	while ((path = enumerator.nextObject()) !== null) {
+		const attrs = enumerator.fileAttributes()
+
+		const type: string = attrs.objectForKey_('NSFileType').toString()
+		const size: number = attrs.objectForKey_('NSFileSize').doubleValue()
+		const mode: number = attrs.objectForKey_('NSFilePosixPermissions').unsignedShortValue()
+		const mtime: number = attrs.objectForKey_('NSFileModificationDate').timeIntervalSince1970()
+
+		entries.push({
+			path: path.toString(),
+			type: ({ NSFileTypeDirectory: 'directory', NSFileTypeRegular: 'file' } as Record<string, FileEntry['type']>)[type] || 'other',
+			size,
+			mode,
+			mtime,
+		})
+	}
+
+	return entries
+})
+
+/**
+ * Stream the content of a file as "chunk" messages
+ * @param path path of the file
+ * @param offset offset to start reading at
+ * @param chunkSize maximum size of each chunk
+ * @returns offset after the last chunk
+ */
+exportFunction('read', function (path: string, offset: number, chunkSize: number): number {
+	let file: File
+
+	try {
+		file = new File(path, 'rb')
+	} catch (e: any) {
+		throw new ScriptError('file-not-found', `file "${path}" not found: ${e.message}`)
+	}
+
+	try {
+		file.seek(offset)
+
+		for (;;) {
+			const chunk = file.readBytes(chunkSize)
+			if (chunk.byteLength === 0) {
+				break
+			}
+
+			send({ type: 'chunk', path, offset }, chunk)
+			offset += chunk.byteLength
+		}
+	} finally {
+		file.close()
+	}
+
+	return offset
+})
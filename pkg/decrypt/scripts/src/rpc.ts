@@ -0,0 +1,52 @@
+/**
+ * @file RPC helpers shared by all scripts
+ * @version 1.1.0
+ */
+
+/**
+ * Error reported to the caller with a machine-readable code
+ */
+class ScriptError extends Error {
+	code: string
+
+	/**
+	 * @param code error code (e.g. "bundle-not-found")
+	 * @param message human-readable message
+	 */
+	constructor(code: string, message: string) {
+		super(message)
+		this.code = code
+	}
+}
+
+/**
+ * Export a function via RPC. The result is wrapped into an envelope, so errors can be told apart from results.
+ * @param name name of the export
+ * @param fn implementation of the export
+ */
+function exportFunction(name: string, fn: (...args: any[]) => unknown) {
+	rpc.exports[name] = function (...args: any[]) {
+		try {
+			return { result: fn(...args) }
+		} catch (e: any) {
+			return { error: { code: e.code || 'script-error', message: e.message || String(e), stack: e.stack || '' } }
+		}
+	}
+}
+
+/**
+ * Ensure the Objective-C runtime is available
+ */
+function requireObjC() {
+	if (typeof ObjC === 'undefined' || !ObjC.available) {
+		throw new ScriptError('objc-unavailable', 'Objective-C runtime not available')
+	}
+}
+
+/**
+ * Get the version of the Frida runtime the script is running in
+ * @returns Frida version
+ */
+exportFunction('version', function (): string {
+	return Frida.version
+})
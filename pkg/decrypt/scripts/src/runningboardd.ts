@@ -0,0 +1,83 @@
+/**
+ * @file Look up applications and their extensions via runningboardd
+ * @version 1.0.0
+ */
+
+/**
+ * Extension of an application
+ */
+interface ExtensionInfo {
+	id: string
+	path: string
+	executable: string
+	absolutePath: string
+}
+
+/*
+ * Get application proxy by bundle identifier
+ * @param bundleId bundle id of the app
+ * @returns application proxy object
+ */
+function getApp(bundleId: string): ObjC.Object {
+	requireObjC()
+
+	// Get application proxy for the given bundle identifier.
+	const app = ObjC.classes.LSApplicationProxy.applicationProxyForIdentifier_(bundleId)
+	if (!app || !app.bundleURL()) {
+		throw new ScriptError('bundle-not-found', `bundle identifier "${bundleId}" not found`)
+	}
+
+	return app
+}
+
+/**
+ * Strip the "/private" prefix of a path, which is a symlink target on iOS
+ * @param path path to normalize
+ * @returns normalized path
+ */
+function normalizePath(path: string): string {
+	return path.startsWith('/private/') ? path.substring('/private'.length) : path
+}
+
+/**
+ * Get list of extensions for the host app
+ * @param bundleId bundle id of the app
+ * @returns list of extensions
+ */
+exportFunction('extensions', function (bundleId: string): ExtensionInfo[] {
+	const app = getApp(bundleId)
+	const appPath = normalizePath(app.bundleURL().path().toString())
+
+	// Iterate through plugins to find extensions
+	var extensions: ExtensionInfo[] = []
+
+	const plugins = app.plugInKitPlugins()
+
+	for (let i = 0; i < plugins.count(); i++) {
+		const plugin = plugins.objectAtIndex_(i)
+
+		const id: string = plugin.bundleIdentifier().toString()
+		const absolutePath = normalizePath(plugin.bundleURL().path().toString())
+
+		const executable = plugin.infoPlist().objectForKey_('CFBundleExecutable')
+		if (!executable) {
+			throw new ScriptError('invalid-extension', `extension "${id}" has no executable`)
+		}
+
+		// Path relative to the app bundle, if the extension is part of it
+		const path = absolutePath.startsWith(appPath + '/') ? absolutePath.substring(appPath.length + 1) : absolutePath
+
+		extensions.push({ id, path, executable: executable.toString(), absolutePath: absolutePath + '/' + executable })
+	}
+
+	return extensions
+})
+
+/**
+ * Get main executable of the app
+ * @param bundleId bundle id of the app
+ * @returns path to the main executable
+ */
+exportFunction('main', function (bundleId: string): string {
+	return getApp(bundleId).bundleExecutable().toString()
+})
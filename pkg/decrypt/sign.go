@@ -0,0 +1,381 @@
+package decrypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	// csFlagAdhoc is the CodeDirectory flag of ad-hoc signatures.
+	csFlagAdhoc = 0x2
+
+	// csHashTypeSHA256 is the CodeDirectory hash type of SHA-256 page hashes.
+	csHashTypeSHA256 = 2
+
+	// csCodeDirectoryVersion is the CodeDirectory version with executable segment fields.
+	csCodeDirectoryVersion = 0x20400
+
+	// csCodeDirectoryHeaderSize is the size of a CodeDirectory header of version csCodeDirectoryVersion.
+	csCodeDirectoryHeaderSize = 88
+
+	// csPageSizeLog2 is the binary logarithm of the size of hashed code pages.
+	csPageSizeLog2 = 12
+
+	// csExecSegMainBinary is the executable segment flag of main binaries.
+	csExecSegMainBinary = 0x1
+)
+
+// csExecSegEntitlements maps entitlements to the executable segment flags they enable.
+var csExecSegEntitlements = map[string]uint64{
+	"get-task-allow":                            0x10,
+	"run-unsigned-code":                         0x10,
+	"com.apple.private.cs.debugger":             0x20,
+	"dynamic-codesigning":                       0x40,
+	"com.apple.private.skip-library-validation": 0x80,
+	"com.apple.private.amfi.can-load-cdhash":    0x100,
+	"com.apple.private.amfi.can-execute-cdhash": 0x200,
+}
+
+// SignOptions configures ad-hoc signing.
+type SignOptions struct {
+	KeepEntitlements bool // KeepEntitlements embeds the entitlements of the original code signatures.
+}
+
+// adhocSignature describes an ad-hoc code signature, which consists of a CodeDirectory, empty requirements, optional
+// entitlements and an empty CMS signature.
+type adhocSignature struct {
+	identifier      string            // identifier is the signing identifier.
+	special         map[uint32][]byte // special are the hashes of special slots, by slot.
+	entitlements    []byte            // entitlements is the XML entitlements blob (nil if none).
+	derEntitlements []byte            // derEntitlements is the DER entitlements blob (nil if none).
+	execSegBase     uint64            // execSegBase is the file offset of the __TEXT segment.
+	execSegLimit    uint64            // execSegLimit is the file size of the __TEXT segment.
+	execSegFlags    uint64            // execSegFlags are the executable segment flags.
+}
+
+// build builds the code signature SuperBlob for code up to codeLimit, with the concatenated page hashes. It returns
+// the SuperBlob and the CodeDirectory.
+func (s *adhocSignature) build(codeLimit uint32, pageHashes []byte) ([]byte, []byte) {
+	// Empty requirements
+	requirements := binary.BigEndian.AppendUint32(nil, csMagicRequirements)
+	requirements = binary.BigEndian.AppendUint32(requirements, 12)
+	requirements = binary.BigEndian.AppendUint32(requirements, 0)
+
+	// Hash special slots
+	special := map[uint32][]byte{csSlotRequirements: sha256Sum(requirements)}
+
+	for slot, hash := range s.special {
+		special[slot] = hash
+	}
+
+	if s.entitlements != nil {
+		special[csSlotEntitlements] = sha256Sum(s.entitlements)
+	}
+
+	if s.derEntitlements != nil {
+		special[csSlotDEREntitlements] = sha256Sum(s.derEntitlements)
+	}
+
+	specialSlots := slices.Max(slices.Collect(maps.Keys(special)))
+
+	// Build CodeDirectory
+	hashOffset := csCodeDirectoryHeaderSize + len(s.identifier) + 1 + int(specialSlots)*sha256.Size
+
+	cd := make([]byte, hashOffset+len(pageHashes))
+
+	binary.BigEndian.PutUint32(cd[0:], csMagicCodeDirectory)
+	binary.BigEndian.PutUint32(cd[4:], uint32(len(cd)))
+	binary.BigEndian.PutUint32(cd[8:], csCodeDirectoryVersion)
+	binary.BigEndian.PutUint32(cd[12:], csFlagAdhoc)
+	binary.BigEndian.PutUint32(cd[16:], uint32(hashOffset))
+	binary.BigEndian.PutUint32(cd[20:], csCodeDirectoryHeaderSize)
+	binary.BigEndian.PutUint32(cd[24:], specialSlots)
+	binary.BigEndian.PutUint32(cd[28:], uint32(len(pageHashes)/sha256.Size))
+	binary.BigEndian.PutUint32(cd[32:], codeLimit)
+	cd[36] = sha256.Size
+	cd[37] = csHashTypeSHA256
+	cd[39] = csPageSizeLog2
+	binary.BigEndian.PutUint64(cd[64:], s.execSegBase)
+	binary.BigEndian.PutUint64(cd[72:], s.execSegLimit)
+	binary.BigEndian.PutUint64(cd[80:], s.execSegFlags)
+
+	copy(cd[csCodeDirectoryHeaderSize:], s.identifier)
+
+	for slot, hash := range special {
+		copy(cd[hashOffset-int(slot)*sha256.Size:], hash)
+	}
+
+	copy(cd[hashOffset:], pageHashes)
+
+	// Empty CMS signature
+	cms := binary.BigEndian.AppendUint32(nil, csMagicBlobWrapper)
+	cms = binary.BigEndian.AppendUint32(cms, 8)
+
+	// Assemble SuperBlob
+	type blob struct {
+		slot uint32
+		data []byte
+	}
+
+	blobs := []blob{{csSlotCodeDirectory, cd}, {csSlotRequirements, requirements}}
+
+	if s.entitlements != nil {
+		blobs = append(blobs, blob{csSlotEntitlements, s.entitlements})
+	}
+
+	if s.derEntitlements != nil {
+		blobs = append(blobs, blob{csSlotDEREntitlements, s.derEntitlements})
+	}
+
+	blobs = append(blobs, blob{csSlotSignature, cms})
+
+	offset := 12 + 8*len(blobs)
+	index := make([]byte, 0, offset)
+
+	index = binary.BigEndian.AppendUint32(index, csMagicEmbeddedSignature)
+	index = binary.BigEndian.AppendUint32(index, 0)
+	index = binary.BigEndian.AppendUint32(index, uint32(len(blobs)))
+
+	var content []byte
+
+	for _, b := range blobs {
+		index = binary.BigEndian.AppendUint32(index, b.slot)
+		index = binary.BigEndian.AppendUint32(index, uint32(offset+len(content)))
+		content = append(content, b.data...)
+	}
+
+	superBlob := append(index, content...)
+	binary.BigEndian.PutUint32(superBlob[4:], uint32(len(superBlob)))
+
+	return superBlob, cd
+}
+
+// signBinary ad-hoc signs the thin 64-bit Mach-O binary name in the local directory dir, replacing its code signature.
+// The signing identifier is taken from the original code signature, falling back to identifier. Special slot hashes
+// (e.g. of Info.plist) are embedded as given. It returns the CDHash of the new signature.
+func signBinary(dir string, name string, identifier string, special map[uint32][]byte, opts SignOptions) ([]byte, error) {
+	fsys := DirFS(dir)
+	file := filepath.Join(dir, filepath.FromSlash(name))
+
+	// Parse binary and its original code signature
+	info, err := parseMachOFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("parse binary: %w", err)
+	}
+
+	if info == nil {
+		return nil, fmt.Errorf("not a thin 64-bit Mach-O binary")
+	}
+
+	sig := &adhocSignature{identifier: identifier, special: special}
+
+	data, err := readCodeSignature(fsys, info)
+	if err != nil {
+		return nil, fmt.Errorf("read code signature: %w", err)
+	}
+
+	if data != nil {
+		original, err := ParseCodeSignature(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse code signature: %w", err)
+		}
+
+		if cd := original.CodeDirectory(); cd.Identifier != "" {
+			sig.identifier = cd.Identifier
+		}
+
+		// Keep original entitlements, and the executable segment flags they imply
+		if opts.KeepEntitlements {
+			blobs, err := codeSignatureBlobs(data)
+			if err != nil {
+				return nil, fmt.Errorf("parse code signature: %w", err)
+			}
+
+			sig.entitlements = blobs[csSlotEntitlements]
+			sig.derEntitlements = blobs[csSlotDEREntitlements]
+
+			for key, value := range original.EffectiveEntitlements() {
+				if value == true {
+					sig.execSegFlags |= csExecSegEntitlements[key]
+				}
+			}
+		}
+	}
+
+	if info.FileType == MH_EXECUTE {
+		sig.execSegFlags |= csExecSegMainBinary
+	}
+
+	// Strip original code signature
+	_, err = StripCodeSignature(file)
+	if err != nil {
+		return nil, fmt.Errorf("strip code signature: %w", err)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read binary: %w", err)
+	}
+
+	// Locate segments and the space available for load commands
+	var h machOHeader
+
+	err = binary.Read(bytes.NewReader(content), binary.LittleEndian, &h)
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	cmdsStart := binary.Size(h)
+	cmdsEnd := cmdsStart + int(h.LoadCmdSize)
+
+	if cmdsEnd > len(content) {
+		return nil, fmt.Errorf("read load commands: %w", io.ErrUnexpectedEOF)
+	}
+
+	firstSection := len(content)
+	textCmd, linkeditCmd := -1, -1
+
+	for i, offset := uint32(0), cmdsStart; i < h.LoadCmdCount; i++ {
+		if offset+8 > cmdsEnd {
+			return nil, fmt.Errorf("read load command [%d]: %w", i, io.ErrUnexpectedEOF)
+		}
+
+		cmdType := binary.LittleEndian.Uint32(content[offset:])
+		cmdSize := int(binary.LittleEndian.Uint32(content[offset+4:]))
+
+		if cmdSize < 8 || offset+cmdSize > cmdsEnd {
+			return nil, fmt.Errorf("invalid size of load command [%d]: %d bytes", i, cmdSize)
+		}
+
+		if cmdType == LC_SEGMENT_64 && cmdSize >= 72 {
+			switch segmentName(content[offset+8 : offset+24]) {
+			case "__TEXT":
+				textCmd = offset
+			case linkeditSegmentName:
+				linkeditCmd = offset
+			}
+
+			// Sections (80 bytes each) follow the segment command
+			sections := int(binary.LittleEndian.Uint32(content[offset+64:]))
+
+			for s := 0; s < sections && 72+(s+1)*80 <= cmdSize; s++ {
+				if sectionOffset := int(binary.LittleEndian.Uint32(content[offset+72+s*80+48:])); sectionOffset != 0 {
+					firstSection = min(firstSection, sectionOffset)
+				}
+			}
+		}
+
+		offset += cmdSize
+	}
+
+	if textCmd < 0 || linkeditCmd < 0 {
+		return nil, fmt.Errorf("no __TEXT or %s segment", linkeditSegmentName)
+	}
+
+	if cmdsEnd+16 > firstSection || !isZero(content[cmdsEnd:cmdsEnd+16]) {
+		return nil, fmt.Errorf("no space for code signature load command")
+	}
+
+	sig.execSegBase = binary.LittleEndian.Uint64(content[textCmd+40:])
+	sig.execSegLimit = binary.LittleEndian.Uint64(content[textCmd+48:])
+
+	// Align code signature to 16 bytes, and determine its size
+	codeLimit := (len(content) + 15) &^ 15
+	if codeLimit > math.MaxUint32 {
+		return nil, fmt.Errorf("binary too large: %d bytes", len(content))
+	}
+
+	content = append(content, make([]byte, codeLimit-len(content))...)
+
+	pageSize := 1 << csPageSizeLog2
+	pages := (codeLimit + pageSize - 1) / pageSize
+
+	superBlob, _ := sig.build(uint32(codeLimit), make([]byte, pages*sha256.Size))
+	sigSize := (len(superBlob) + 15) &^ 15
+
+	// Add LC_CODE_SIGNATURE load command
+	cmd := content[cmdsEnd : cmdsEnd+16]
+
+	binary.LittleEndian.PutUint32(cmd[0:], LC_CODE_SIGNATURE)
+	binary.LittleEndian.PutUint32(cmd[4:], 16)
+	binary.LittleEndian.PutUint32(cmd[8:], uint32(codeLimit))
+	binary.LittleEndian.PutUint32(cmd[12:], uint32(sigSize))
+
+	h.LoadCmdCount++
+	h.LoadCmdSize += 16
+
+	var buf bytes.Buffer
+
+	err = binary.Write(&buf, binary.LittleEndian, &h)
+	if err != nil {
+		return nil, fmt.Errorf("encode header: %w", err)
+	}
+
+	copy(content, buf.Bytes())
+
+	// Extend __LINKEDIT segment to cover the code signature
+	segment := content[linkeditCmd:]
+	fileOff := binary.LittleEndian.Uint64(segment[40:])
+
+	if fileOff > uint64(codeLimit) {
+		return nil, fmt.Errorf("%s segment starts after the end of the binary", linkeditSegmentName)
+	}
+
+	fileSize := uint64(codeLimit+sigSize) - fileOff
+	vmSize := max(binary.LittleEndian.Uint64(segment[32:]), (fileSize+segmentPageSize-1)&^(segmentPageSize-1))
+
+	binary.LittleEndian.PutUint64(segment[32:], vmSize)
+	binary.LittleEndian.PutUint64(segment[48:], fileSize)
+
+	// Hash code pages, and build the final code signature
+	pageHashes := make([]byte, 0, pages*sha256.Size)
+
+	for page := range pages {
+		pageHashes = append(pageHashes, sha256Sum(content[page*pageSize:min((page+1)*pageSize, codeLimit)])...)
+	}
+
+	superBlob, cd := sig.build(uint32(codeLimit), pageHashes)
+
+	content = append(content, superBlob...)
+	content = append(content, make([]byte, sigSize-len(superBlob))...)
+
+	// Write signed binary
+	err = os.WriteFile(file, content, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("write binary: %w", err)
+	}
+
+	return sha256Sum(cd)[:20], nil
+}
+
+// signingIdentifier returns the fallback signing identifier of a binary, which is its file name without extension.
+func signingIdentifier(name string) string {
+	base := path.Base(name)
+	return base[:len(base)-len(path.Ext(base))]
+}
+
+// sha256Sum returns the SHA-256 hash of data.
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// isZero returns true if all bytes of data are zero.
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
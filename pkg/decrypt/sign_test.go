@@ -0,0 +1,246 @@
+package decrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOriginalSignature builds a code signature with an identifier and entitlements, like a distribution signature.
+func testOriginalSignature(t *testing.T) []byte {
+	t.Helper()
+
+	return testSuperBlob(csMagicEmbeddedSignature,
+		testSlotBlob{Slot: csSlotCodeDirectory, Blob: testCodeDirectory("com.example.original", "TEAM123456", 2, 8)},
+		testSlotBlob{Slot: csSlotRequirements, Blob: testSuperBlob(csMagicRequirements)},
+		testSlotBlob{Slot: csSlotEntitlements, Blob: testBlob(csMagicEmbeddedEntitlements, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>get-task-allow</key><true/></dict></plist>`))},
+		testSlotBlob{Slot: csSlotDEREntitlements, Blob: testBlob(csMagicEmbeddedDEREntitlements, testDEREntitlements(t, "get-task-allow", true))},
+		testSlotBlob{Slot: csSlotSignature, Blob: testBlob(csMagicBlobWrapper, []byte{0x30, 0x80, 0x00, 0x00})})
+}
+
+func TestSignBinary(t *testing.T) {
+	const linkeditSize = 0x120
+
+	info := sha256Sum([]byte("Info.plist"))
+	resources := sha256Sum([]byte("CodeResources"))
+
+	tests := []struct {
+		name             string            // name is the name of the test.
+		data             []byte            // data is the binary signed.
+		special          map[uint32][]byte // special are the special slot hashes embedded.
+		opts             SignOptions       // opts configures signing.
+		wantIdentifier   string            // wantIdentifier is the expected signing identifier.
+		wantEntitlements bool              // wantEntitlements is true if entitlements are expected to be embedded.
+		wantExecSegFlags uint64            // wantExecSegFlags are the expected executable segment flags.
+	}{
+		{
+			name:             "unsigned executable",
+			data:             testBinary(MH_EXECUTE, linkeditSize, nil, 0),
+			special:          map[uint32][]byte{csSlotInfo: info, csSlotResourceDir: resources},
+			wantIdentifier:   "Ex",
+			wantExecSegFlags: csExecSegMainBinary,
+		},
+		{
+			name:           "unsigned dylib",
+			data:           testBinary(MH_DYLIB, linkeditSize, nil, 0),
+			wantIdentifier: "Ex",
+		},
+		{
+			name:             "signed executable",
+			data:             testBinary(MH_EXECUTE, linkeditSize, testOriginalSignature(t), 0),
+			special:          map[uint32][]byte{csSlotInfo: info, csSlotResourceDir: resources},
+			wantIdentifier:   "com.example.original",
+			wantExecSegFlags: csExecSegMainBinary,
+		},
+		{
+			name:             "signed executable with entitlements",
+			data:             testBinary(MH_EXECUTE, linkeditSize, testOriginalSignature(t), 0),
+			special:          map[uint32][]byte{csSlotInfo: info, csSlotResourceDir: resources},
+			opts:             SignOptions{KeepEntitlements: true},
+			wantIdentifier:   "com.example.original",
+			wantEntitlements: true,
+			wantExecSegFlags: csExecSegMainBinary | 0x10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := os.WriteFile(filepath.Join(dir, "Ex"), tt.data, 0755)
+			if err != nil {
+				t.Fatalf("write binary: %v", err)
+			}
+
+			// Sign twice, which must give the same result
+			cdhash, err := signBinary(dir, "Ex", "Ex", tt.special, tt.opts)
+			if err != nil {
+				t.Fatalf("signBinary() = %v", err)
+			}
+
+			signed, err := os.ReadFile(filepath.Join(dir, "Ex"))
+			if err != nil {
+				t.Fatalf("read binary: %v", err)
+			}
+
+			again, err := signBinary(dir, "Ex", "Ex", tt.special, tt.opts)
+			if err != nil {
+				t.Fatalf("signBinary() again = %v", err)
+			}
+
+			resigned, err := os.ReadFile(filepath.Join(dir, "Ex"))
+			if err != nil {
+				t.Fatalf("read binary: %v", err)
+			}
+
+			if !bytes.Equal(cdhash, again) || !bytes.Equal(signed, resigned) {
+				t.Errorf("signing again changed the CDHash from %x to %x", cdhash, again)
+			}
+
+			// Code is left unchanged
+			if !bytes.Equal(signed[testSectionOffset:testTextSize+linkeditSize], tt.data[testSectionOffset:testTextSize+linkeditSize]) {
+				t.Errorf("code changed by signing")
+			}
+
+			checkAdhocSignature(t, signed, cdhash)
+
+			// Parse signature
+			info, cs := parseTestSignature(t, signed)
+
+			cd := cs.CodeDirectory()
+			if cd.Identifier != tt.wantIdentifier || cd.TeamID != "" || cd.Flags != csFlagAdhoc || cs.Signed {
+				t.Errorf("code directory = %+v, signed = %t, want ad-hoc signature of %q", cd, cs.Signed, tt.wantIdentifier)
+			}
+
+			if cd.CDHash != hex.EncodeToString(cdhash) {
+				t.Errorf("CDHash = %s, want %x", cd.CDHash, cdhash)
+			}
+
+			if (cs.Entitlements != nil) != tt.wantEntitlements || (cs.DEREntitlements != nil) != tt.wantEntitlements {
+				t.Errorf("entitlements = %v, %v, want embedded %t", cs.Entitlements, cs.DEREntitlements, tt.wantEntitlements)
+			}
+
+			// Check special slots and executable segment
+			blobs, err := codeSignatureBlobs(signed[info.CodeSigOffset:])
+			if err != nil {
+				t.Fatalf("codeSignatureBlobs() = %v", err)
+			}
+
+			blob := blobs[csSlotCodeDirectory]
+			hashOffset := int(binary.BigEndian.Uint32(blob[16:]))
+
+			specialSlot := func(slot uint32) []byte {
+				return blob[hashOffset-int(slot)*32 : hashOffset-int(slot-1)*32]
+			}
+
+			for slot, hash := range tt.special {
+				if !bytes.Equal(specialSlot(slot), hash) {
+					t.Errorf("special slot [%d] = %x, want %x", slot, specialSlot(slot), hash)
+				}
+			}
+
+			if !bytes.Equal(specialSlot(csSlotRequirements), sha256Sum(blobs[csSlotRequirements])) {
+				t.Errorf("special slot [%d] doesn't match requirements", csSlotRequirements)
+			}
+
+			if tt.wantEntitlements && !bytes.Equal(specialSlot(csSlotDEREntitlements), sha256Sum(blobs[csSlotDEREntitlements])) {
+				t.Errorf("special slot [%d] doesn't match DER entitlements", csSlotDEREntitlements)
+			}
+
+			execSegBase, execSegLimit, execSegFlags := binary.BigEndian.Uint64(blob[64:]), binary.BigEndian.Uint64(blob[72:]), binary.BigEndian.Uint64(blob[80:])
+			if execSegBase != 0 || execSegLimit != testTextSize || execSegFlags != tt.wantExecSegFlags {
+				t.Errorf("executable segment = 0x%x+0x%x [0x%x], want 0x0+0x%x [0x%x]", execSegBase, execSegLimit, execSegFlags, testTextSize, tt.wantExecSegFlags)
+			}
+		})
+	}
+}
+
+func TestSignBinaryNoSpace(t *testing.T) {
+	// Move the first section right after the load commands
+	data := testBinary(MH_EXECUTE, 0x120, nil, 0)
+	binary.LittleEndian.PutUint32(data[32+72+48:], uint32(32+binary.LittleEndian.Uint32(data[20:])+8))
+
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "Ex"), data, 0755)
+	if err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+
+	_, err = signBinary(dir, "Ex", "Ex", nil, SignOptions{})
+	if err == nil {
+		t.Fatalf("signBinary() succeeded, want error")
+	}
+}
+
+// parseTestSignature parses a signed binary and its code signature.
+func parseTestSignature(t *testing.T, data []byte) (*MachOInfo, *CodeSignature) {
+	t.Helper()
+
+	info, err := parseMachO(bytes.NewReader(data), "Ex")
+	if err != nil || info == nil {
+		t.Fatalf("parseMachO() = %v, %v", info, err)
+	}
+
+	if info.CodeSigCommandOffset == 0 {
+		t.Fatalf("binary is not signed")
+	}
+
+	cs, err := ParseCodeSignature(data[info.CodeSigOffset : info.CodeSigOffset+info.CodeSigSize])
+	if err != nil {
+		t.Fatalf("ParseCodeSignature() = %v", err)
+	}
+
+	return info, cs
+}
+
+// checkAdhocSignature checks that the code signature of a signed binary is at its end, covered by __LINKEDIT, and
+// that its page hashes match the content of the binary.
+func checkAdhocSignature(t *testing.T, data []byte, cdhash []byte) {
+	t.Helper()
+
+	info, _ := parseTestSignature(t, data)
+
+	// Code signature at the end of the binary, within __LINKEDIT
+	if info.CodeSigOffset%16 != 0 || int(info.CodeSigOffset+info.CodeSigSize) != len(data) {
+		t.Errorf("code signature at 0x%x+0x%x, want it 16 byte aligned at the end (0x%x)", info.CodeSigOffset, info.CodeSigSize, len(data))
+	}
+
+	linkedit := data[testLinkeditCommandOffset:]
+	if fileOff, fileSize, vmSize := binary.LittleEndian.Uint64(linkedit[40:]), binary.LittleEndian.Uint64(linkedit[48:]), binary.LittleEndian.Uint64(linkedit[32:]); fileOff+fileSize != uint64(len(data)) || vmSize < fileSize {
+		t.Errorf("__LINKEDIT at 0x%x+0x%x (vmsize 0x%x), want it to end at 0x%x", fileOff, fileSize, vmSize, len(data))
+	}
+
+	// Re-hash code pages
+	blobs, err := codeSignatureBlobs(data[info.CodeSigOffset:])
+	if err != nil {
+		t.Fatalf("codeSignatureBlobs() = %v", err)
+	}
+
+	cd := blobs[csSlotCodeDirectory]
+
+	if !bytes.Equal(sha256Sum(cd)[:20], cdhash) {
+		t.Errorf("CDHash = %x, want %x", sha256Sum(cd)[:20], cdhash)
+	}
+
+	hashOffset := int(binary.BigEndian.Uint32(cd[16:]))
+	codeSlots := int(binary.BigEndian.Uint32(cd[28:]))
+	codeLimit := int(binary.BigEndian.Uint32(cd[32:]))
+	pageSize := 1 << cd[39]
+
+	if codeLimit != int(info.CodeSigOffset) || codeSlots != (codeLimit+pageSize-1)/pageSize {
+		t.Fatalf("code limit = 0x%x with %d slots, want 0x%x", codeLimit, codeSlots, info.CodeSigOffset)
+	}
+
+	for page := range codeSlots {
+		hash := sha256Sum(data[page*pageSize : min((page+1)*pageSize, codeLimit)])
+
+		if got := cd[hashOffset+page*32 : hashOffset+(page+1)*32]; !bytes.Equal(got, hash) {
+			t.Errorf("hash of page [%d] = %x, want %x", page, got, hash)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package decrypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+)
+
+// ProvisioningProfile describes an embedded provisioning profile.
+type ProvisioningProfile struct {
+	Path                 string         `json:"path"`                 // Path is the path of the profile, relative to the app bundle.
+	Name                 string         `json:"name"`                 // Name is the name of the profile.
+	UUID                 string         `json:"uuid"`                 // UUID identifies the profile.
+	AppIDName            string         `json:"appIdName"`            // AppIDName is the name of the app ID.
+	TeamID               string         `json:"teamId"`               // TeamID is the identifier of the team.
+	TeamName             string         `json:"teamName"`             // TeamName is the name of the team.
+	CreationDate         time.Time      `json:"creationDate"`         // CreationDate is the time the profile was created.
+	ExpirationDate       time.Time      `json:"expirationDate"`       // ExpirationDate is the time the profile expires.
+	Entitlements         map[string]any `json:"entitlements"`         // Entitlements are granted by the profile.
+	Devices              []string       `json:"devices,omitempty"`    // Devices are the UDIDs of provisioned devices.
+	ProvisionsAllDevices bool           `json:"provisionsAllDevices"` // ProvisionsAllDevices is set for enterprise profiles.
+	Platforms            []string       `json:"platforms,omitempty"`  // Platforms the profile is valid for (e.g. "iOS").
+}
+
+// BinarySigning describes the signature of a binary.
+type BinarySigning struct {
+	Path         string         `json:"path"`                   // Path is the path of the binary, relative to the app bundle.
+	Entitlements map[string]any `json:"entitlements,omitempty"` // Entitlements are embedded into the code signature.
+}
+
+// SigningArtifact describes a file that is removed from the app bundle by the cleanup.
+type SigningArtifact struct {
+	Path   string `json:"path"`   // Path is the path of the file, relative to the app bundle.
+	Size   int64  `json:"size"`   // Size is the size of the file in bytes.
+	SHA256 string `json:"sha256"` // SHA256 is the hex encoded SHA-256 hash of the file.
+}
+
+// SigningReport describes the signing artifacts of the original app bundle.
+type SigningReport struct {
+	Profiles  []ProvisioningProfile `json:"profiles"`  // Profiles are all embedded provisioning profiles.
+	Binaries  []BinarySigning       `json:"binaries"`  // Binaries are all signed Mach-O binaries.
+	Artifacts []SigningArtifact     `json:"artifacts"` // Artifacts are the files removed by the cleanup.
+}
+
+// buildSigningReport collects the signing artifacts of the app bundle fsys, which must not have been cleaned up yet.
+// Files that can't be parsed are skipped with a warning.
+func buildSigningReport(fsys fs.FS, rules CleanupRules) (*SigningReport, error) {
+	report := &SigningReport{Profiles: []ProvisioningProfile{}, Binaries: []BinarySigning{}, Artifacts: []SigningArtifact{}}
+
+	// Collect provisioning profiles and files removed by the cleanup
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if name == "." {
+			return nil
+		}
+
+		// Record everything below matching directories
+		if rules.matches(name, d.IsDir()) {
+			artifacts, err := hashFiles(fsys, name)
+			if err != nil {
+				return fmt.Errorf("hash artifacts [%s]: %w", name, err)
+			}
+
+			report.Artifacts = append(report.Artifacts, artifacts...)
+
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+		}
+
+		// Parse provisioning profiles
+		if !d.IsDir() && path.Base(name) == "embedded.mobileprovision" {
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return fmt.Errorf("read provisioning profile [%s]: %w", name, err)
+			}
+
+			profile, err := parseProvisioningProfile(data)
+			if err != nil {
+				slog.Warn("Failed to parse provisioning profile", slog.String("path", name), slog.Any("error", err))
+				return nil
+			}
+
+			profile.Path = name
+			report.Profiles = append(report.Profiles, *profile)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("walk directory: %w", err)
+	}
+
+	// Collect entitlements of all binaries
+	binaries, err := ScanBinaries(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("scan binaries: %w", err)
+	}
+
+	for _, info := range binaries {
+		if info.Signature == nil {
+			continue
+		}
+
+		report.Binaries = append(report.Binaries, BinarySigning{Path: info.Path, Entitlements: info.Signature.EffectiveEntitlements()})
+	}
+
+	return report, nil
+}
+
+// writeSigningReport writes the report as JSON to a file.
+func writeSigningReport(report *SigningReport, output string) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode report: %w", err)
+	}
+
+	return os.WriteFile(output, append(content, '\n'), 0644)
+}
+
+// hashFiles hashes the file at name, or all files below it if it's a directory.
+func hashFiles(fsys fs.FS, name string) ([]SigningArtifact, error) {
+	var artifacts []SigningArtifact
+
+	err := fs.WalkDir(fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		file, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		h := sha256.New()
+
+		size, err := io.Copy(h, file)
+		if err != nil {
+			return err
+		}
+
+		artifacts = append(artifacts, SigningArtifact{Path: p, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))})
+
+		return nil
+	})
+
+	return artifacts, err
+}
+
+// cmsContentInfo is the outer structure of a CMS message (RFC 5652).
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// cmsSignedData is the beginning of the CMS SignedData structure, up to the signed content.
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     []byte `asn1:"explicit,optional,tag:0"`
+	}
+}
+
+// parseProvisioningProfile parses a provisioning profile, which is a property list signed as CMS message.
+func parseProvisioningProfile(data []byte) (*ProvisioningProfile, error) {
+	// Extract property list from CMS message
+	content, err := cmsContent(data)
+	if err != nil {
+		// Fall back to locating the property list, as CMS messages might use BER encoding
+		start, end := bytes.Index(data, []byte("<?xml")), bytes.LastIndex(data, []byte("</plist>"))
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("extract content: %w", err)
+		}
+
+		content = data[start : end+len("</plist>")]
+	}
+
+	value, err := parseXMLPlist(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse property list: %w", err)
+	}
+
+	dict, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("property list is not a dictionary")
+	}
+
+	// Map properties
+	profile := &ProvisioningProfile{}
+
+	profile.Name, _ = dict["Name"].(string)
+	profile.UUID, _ = dict["UUID"].(string)
+	profile.AppIDName, _ = dict["AppIDName"].(string)
+	profile.TeamName, _ = dict["TeamName"].(string)
+	profile.CreationDate, _ = dict["CreationDate"].(time.Time)
+	profile.ExpirationDate, _ = dict["ExpirationDate"].(time.Time)
+	profile.Entitlements, _ = dict["Entitlements"].(map[string]any)
+	profile.ProvisionsAllDevices, _ = dict["ProvisionsAllDevices"].(bool)
+	profile.Devices = plistStrings(dict["ProvisionedDevices"])
+	profile.Platforms = plistStrings(dict["Platform"])
+
+	if teams := plistStrings(dict["TeamIdentifier"]); len(teams) > 0 {
+		profile.TeamID = teams[0]
+	}
+
+	return profile, nil
+}
+
+// cmsContent returns the signed content of a DER encoded CMS message.
+func cmsContent(data []byte) ([]byte, error) {
+	var ci cmsContentInfo
+
+	_, err := asn1.Unmarshal(data, &ci)
+	if err != nil {
+		return nil, fmt.Errorf("parse content info: %w", err)
+	}
+
+	var sd cmsSignedData
+
+	_, err = asn1.Unmarshal(ci.Content.Bytes, &sd)
+	if err != nil {
+		return nil, fmt.Errorf("parse signed data: %w", err)
+	}
+
+	if len(sd.EncapContentInfo.Content) == 0 {
+		return nil, fmt.Errorf("no signed content")
+	}
+
+	return sd.EncapContentInfo.Content, nil
+}
+
+// plistStrings returns the strings of a property list array.
+func plistStrings(value any) []string {
+	array, _ := value.([]any)
+
+	var strs []string
+
+	for _, v := range array {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+
+	return strs
+}
@@ -0,0 +1,180 @@
+package decrypt
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// testProfile is the property list of a provisioning profile.
+const testProfile = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>AppIDName</key>
+	<string>Example</string>
+	<key>CreationDate</key>
+	<date>2024-01-02T03:04:05Z</date>
+	<key>Entitlements</key>
+	<dict>
+		<key>application-identifier</key>
+		<string>TEAM123456.com.example.ex</string>
+		<key>get-task-allow</key>
+		<false/>
+	</dict>
+	<key>Name</key>
+	<string>Example Distribution</string>
+	<key>Platform</key>
+	<array>
+		<string>iOS</string>
+	</array>
+	<key>ProvisionedDevices</key>
+	<array>
+		<string>00008030-0000000000000001</string>
+	</array>
+	<key>TeamIdentifier</key>
+	<array>
+		<string>TEAM123456</string>
+	</array>
+	<key>TeamName</key>
+	<string>Example Inc.</string>
+	<key>UUID</key>
+	<string>01234567-89ab-cdef-0123-456789abcdef</string>
+</dict>
+</plist>
+`
+
+// testCMS wraps content into a DER encoded CMS SignedData message without signers.
+func testCMS(t *testing.T, content []byte) []byte {
+	t.Helper()
+
+	var sd cmsSignedData
+
+	sd.Version = 1
+	sd.DigestAlgorithms = asn1.RawValue{FullBytes: []byte{0x31, 0x00}}
+	sd.EncapContentInfo.ContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	sd.EncapContentInfo.Content = content
+
+	signedData, err := asn1.Marshal(sd)
+	if err != nil {
+		t.Fatalf("marshal signed data: %v", err)
+	}
+
+	data, err := asn1.Marshal(cmsContentInfo{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData},
+	})
+
+	if err != nil {
+		t.Fatalf("marshal content info: %v", err)
+	}
+
+	return data
+}
+
+func TestParseProvisioningProfile(t *testing.T) {
+	tests := []struct {
+		name    string // name is the name of the test.
+		data    []byte // data is the provisioning profile.
+		wantErr bool   // wantErr is true if parsing is expected to fail.
+	}{
+		{name: "CMS message", data: testCMS(t, []byte(testProfile)), wantErr: false},
+		{name: "embedded property list", data: append(append([]byte{0x30, 0x80, 0x06, 0x09}, testProfile...), 0, 0), wantErr: false},
+		{name: "no property list", data: []byte{0x30, 0x80, 0x06, 0x09, 0x00, 0x00}, wantErr: true},
+		{name: "no dictionary", data: testCMS(t, []byte(`<?xml version="1.0"?><plist version="1.0"><array/></plist>`)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := parseProvisioningProfile(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseProvisioningProfile() = %+v, want error", profile)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseProvisioningProfile() = %v", err)
+			}
+
+			if profile.Name != "Example Distribution" || profile.UUID != "01234567-89ab-cdef-0123-456789abcdef" {
+				t.Errorf("name, UUID = %q, %q", profile.Name, profile.UUID)
+			}
+
+			if profile.TeamID != "TEAM123456" || profile.TeamName != "Example Inc." || profile.AppIDName != "Example" {
+				t.Errorf("team ID, team name, app ID name = %q, %q, %q", profile.TeamID, profile.TeamName, profile.AppIDName)
+			}
+
+			if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !profile.CreationDate.Equal(want) {
+				t.Errorf("creation date = %s, want %s", profile.CreationDate, want)
+			}
+
+			if !slices.Equal(profile.Devices, []string{"00008030-0000000000000001"}) || !slices.Equal(profile.Platforms, []string{"iOS"}) {
+				t.Errorf("devices, platforms = %q, %q", profile.Devices, profile.Platforms)
+			}
+
+			if profile.Entitlements["application-identifier"] != "TEAM123456.com.example.ex" || profile.Entitlements["get-task-allow"] != false {
+				t.Errorf("entitlements = %v", profile.Entitlements)
+			}
+		})
+	}
+}
+
+func TestBuildSigningReport(t *testing.T) {
+	profile := testCMS(t, []byte(testProfile))
+
+	fsys := testBundleFS()
+	fsys["embedded.mobileprovision"] = &fstest.MapFile{Data: profile}
+	fsys["PlugIns/W.appex/embedded.mobileprovision"] = &fstest.MapFile{Data: []byte("invalid")}
+
+	report, err := buildSigningReport(fsys, DefaultCleanupRules())
+	if err != nil {
+		t.Fatalf("buildSigningReport() = %v", err)
+	}
+
+	// Only parseable profiles are reported
+	if len(report.Profiles) != 1 || report.Profiles[0].Path != "embedded.mobileprovision" {
+		t.Errorf("profiles = %+v, want embedded.mobileprovision", report.Profiles)
+	}
+
+	// Every file removed by the cleanup is an artifact
+	var got []string
+	for _, artifact := range report.Artifacts {
+		got = append(got, artifact.Path)
+	}
+
+	want := []string{"PlugIns/W.appex/_CodeSignature/Seal", "_CodeSignature/CodeResources", "embedded.mobileprovision"}
+	if !slices.Equal(got, want) {
+		t.Errorf("artifacts = %q, want %q", got, want)
+	}
+
+	hash := sha256.Sum256(profile)
+
+	for _, artifact := range report.Artifacts {
+		if artifact.Path == "embedded.mobileprovision" && (artifact.SHA256 != hex.EncodeToString(hash[:]) || artifact.Size != int64(len(profile))) {
+			t.Errorf("artifact = %+v, want size %d and hash %x", artifact, len(profile), hash)
+		}
+	}
+}
+
+func TestCMSContent(t *testing.T) {
+	content, err := cmsContent(testCMS(t, []byte(testProfile)))
+	if err != nil {
+		t.Fatalf("cmsContent() = %v", err)
+	}
+
+	if string(content) != testProfile {
+		t.Errorf("cmsContent() = %q, want %q", content, testProfile)
+	}
+
+	_, err = cmsContent(testCMS(t, nil))
+	if err == nil {
+		t.Errorf("cmsContent() without content succeeded, want error")
+	}
+}
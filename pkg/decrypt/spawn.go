@@ -0,0 +1,44 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+)
+
+// SpawnScript spawns an application suspended, loads a Frida script into it before its first instruction runs, and
+// then resumes it. It returns the loaded script and the PID of the spawned process. The caller is responsible for
+// killing the process.
+func (dev *Device) SpawnScript(ctx context.Context, name string, content string, identifier string) (*Script, int, error) {
+	// Spawn application (suspended)
+	pid, err := dev.device.Spawn(identifier, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("spawn application [%s]: %w", identifier, err)
+	}
+
+	// Load script and resume
+	script, err := dev.loadScriptAndResume(ctx, name, content, pid)
+	if err != nil {
+		dev.device.Kill(pid) //nolint
+		return nil, 0, err
+	}
+
+	return script, pid, nil
+}
+
+// loadScriptAndResume loads a Frida script into a suspended process, and resumes it.
+func (dev *Device) loadScriptAndResume(ctx context.Context, name string, content string, pid int) (*Script, error) {
+	// Load script into process
+	script, err := dev.loadScript(ctx, name, content, pid)
+	if err != nil {
+		return nil, fmt.Errorf("load script into process: %w", err)
+	}
+
+	// Resume process
+	err = dev.device.Resume(pid)
+	if err != nil {
+		script.Close()
+		return nil, fmt.Errorf("resume process [%d]: %w", pid, err)
+	}
+
+	return script, nil
+}
@@ -0,0 +1,160 @@
+package decrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// linkeditSegmentName is the name of the segment that holds the code signature.
+	linkeditSegmentName = "__LINKEDIT"
+
+	// segmentPageSize is the page size segments are aligned to on arm64.
+	segmentPageSize = 0x4000
+)
+
+// StripCodeSignature removes the LC_CODE_SIGNATURE load command from a local thin 64-bit Mach-O binary, and truncates
+// the code signature data at the end of the __LINKEDIT segment, so the binary can be re-signed cleanly. It returns
+// false if the binary is not signed. If the code signature is followed by other data (which truncating would cut off),
+// an error is returned. The binary is left untouched if an error is returned.
+func StripCodeSignature(path string) (bool, error) {
+	// Open file
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, fmt.Errorf("open file: %w", err)
+	}
+
+	defer file.Close()
+
+	// Read header and load commands
+	var h machOHeader
+
+	err = binary.Read(file, binary.LittleEndian, &h)
+	if err != nil {
+		return false, fmt.Errorf("read header: %w", err)
+	}
+
+	if h.Magic != MH_MAGIC_64 {
+		return false, fmt.Errorf("not a thin 64-bit Mach-O binary")
+	}
+
+	if h.LoadCmdSize > maxLoadCmdSize {
+		return false, fmt.Errorf("invalid size of load commands: %d bytes", h.LoadCmdSize)
+	}
+
+	cmds := make([]byte, h.LoadCmdSize)
+
+	_, err = io.ReadFull(file, cmds)
+	if err != nil {
+		return false, fmt.Errorf("read load commands: %w", err)
+	}
+
+	// Find code signature and __LINKEDIT segment
+	sigCmd, linkeditCmd := -1, -1
+
+	for i, offset := uint32(0), 0; i < h.LoadCmdCount; i++ {
+		if offset+8 > len(cmds) {
+			return false, fmt.Errorf("read load command [%d]: %w", i, io.ErrUnexpectedEOF)
+		}
+
+		cmdType := binary.LittleEndian.Uint32(cmds[offset:])
+		cmdSize := int(binary.LittleEndian.Uint32(cmds[offset+4:]))
+
+		if cmdSize < 8 || offset+cmdSize > len(cmds) {
+			return false, fmt.Errorf("invalid size of load command [%d]: %d bytes", i, cmdSize)
+		}
+
+		switch {
+		case cmdType == LC_CODE_SIGNATURE && cmdSize >= 16:
+			sigCmd = offset
+		case cmdType == LC_SEGMENT_64 && cmdSize >= 72 && segmentName(cmds[offset+8:offset+24]) == linkeditSegmentName:
+			linkeditCmd = offset
+		}
+
+		offset += cmdSize
+	}
+
+	if sigCmd < 0 {
+		return false, nil
+	}
+
+	if linkeditCmd < 0 {
+		return false, fmt.Errorf("no %s segment", linkeditSegmentName)
+	}
+
+	// Shrink __LINKEDIT segment to end before the code signature
+	sigSize := int(binary.LittleEndian.Uint32(cmds[sigCmd+4:]))
+	dataOff := uint64(binary.LittleEndian.Uint32(cmds[sigCmd+8:]))
+	dataSize := uint64(binary.LittleEndian.Uint32(cmds[sigCmd+12:]))
+
+	segment := cmds[linkeditCmd:]
+	vmSize := binary.LittleEndian.Uint64(segment[32:])
+	fileOff := binary.LittleEndian.Uint64(segment[40:])
+	fileSize := binary.LittleEndian.Uint64(segment[48:])
+
+	if dataOff < fileOff || dataOff+dataSize > fileOff+fileSize {
+		return false, fmt.Errorf("code signature is not within the %s segment", linkeditSegmentName)
+	}
+
+	if fileOff+fileSize-(dataOff+dataSize) >= segmentPageSize {
+		return false, fmt.Errorf("code signature is not at the end of the %s segment", linkeditSegmentName)
+	}
+
+	// Make sure nothing follows the code signature data (including the padding to 16 bytes)
+	info, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("get file info: %w", err)
+	}
+
+	if sigEnd := (dataOff + dataSize + 15) &^ 15; sigEnd < uint64(info.Size()) {
+		return false, fmt.Errorf("code signature is followed by %d bytes of other data", uint64(info.Size())-sigEnd)
+	}
+
+	fileSize = dataOff - fileOff
+	vmSize = min(vmSize, (fileSize+segmentPageSize-1)&^(segmentPageSize-1))
+
+	binary.LittleEndian.PutUint64(segment[32:], vmSize)
+	binary.LittleEndian.PutUint64(segment[48:], fileSize)
+
+	// Remove code signature load command
+	copy(cmds[sigCmd:], cmds[sigCmd+sigSize:])
+	clear(cmds[len(cmds)-sigSize:])
+
+	h.LoadCmdCount--
+	h.LoadCmdSize -= uint32(sigSize)
+
+	// Write header and load commands
+	var buf bytes.Buffer
+
+	err = binary.Write(&buf, binary.LittleEndian, &h)
+	if err != nil {
+		return false, fmt.Errorf("encode header: %w", err)
+	}
+
+	buf.Write(cmds)
+
+	_, err = file.WriteAt(buf.Bytes(), 0)
+	if err != nil {
+		return false, fmt.Errorf("write load commands: %w", err)
+	}
+
+	// Truncate code signature data
+	err = file.Truncate(int64(dataOff))
+	if err != nil {
+		return false, fmt.Errorf("truncate code signature: %w", err)
+	}
+
+	return true, file.Close()
+}
+
+// segmentName returns the NUL padded name of a segment.
+func segmentName(name []byte) string {
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+
+	return string(name)
+}
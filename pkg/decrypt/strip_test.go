@@ -0,0 +1,114 @@
+package decrypt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStripCodeSignature(t *testing.T) {
+	signature := bytes.Repeat([]byte{0xCC}, 0x200)
+
+	outside := testBinary(MH_EXECUTE, 0x100, signature, 0)
+	binary.LittleEndian.PutUint32(outside[testCodeSigCommandOffset+8:], 0x100)
+
+	tests := []struct {
+		name         string // name is the name of the test.
+		data         []byte // data is the binary.
+		wantStripped bool   // wantStripped is true if the code signature is expected to be stripped.
+		wantErr      bool   // wantErr is true if stripping is expected to fail.
+		wantSize     int    // wantSize is the expected size of the file after stripping.
+	}{
+		{name: "signature at end", data: testBinary(MH_EXECUTE, 0x100, signature, 0), wantStripped: true, wantSize: testTextSize + 0x100},
+		{name: "padded signature at end", data: testBinary(MH_EXECUTE, 0x108, signature[:0x1F3], 0), wantStripped: true, wantSize: testTextSize + 0x108},
+		{name: "unsigned", data: testBinary(MH_EXECUTE, 0x100, nil, 0), wantStripped: false, wantSize: testTextSize + 0x100},
+		{name: "data after signature", data: testBinary(MH_EXECUTE, 0x100, signature, 0x10), wantErr: true},
+		{name: "signature outside __LINKEDIT", data: outside, wantErr: true},
+		{name: "not a Mach-O binary", data: []byte("#!/bin/sh\necho hello, world\n"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "Ex")
+
+			err := os.WriteFile(path, tt.data, 0755)
+			if err != nil {
+				t.Fatalf("write binary: %v", err)
+			}
+
+			stripped, err := StripCodeSignature(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("StripCodeSignature() = %t, want error", stripped)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("StripCodeSignature() = %v", err)
+			}
+
+			if stripped != tt.wantStripped {
+				t.Errorf("StripCodeSignature() = %t, want %t", stripped, tt.wantStripped)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read binary: %v", err)
+			}
+
+			if len(data) != tt.wantSize {
+				t.Errorf("size = %d bytes, want %d bytes", len(data), tt.wantSize)
+			}
+
+			// The stripped binary must be unsigned, with __LINKEDIT ending at the end of the file
+			info, err := parseMachO(bufio.NewReader(bytes.NewReader(data)), "Ex")
+			if err != nil || info == nil {
+				t.Fatalf("parseMachO() = %v, %v", info, err)
+			}
+
+			if info.CodeSigCommandOffset != 0 {
+				t.Errorf("code signature command at %d, want none", info.CodeSigCommandOffset)
+			}
+
+			linkedit := data[testLinkeditCommandOffset:]
+			if fileOff, fileSize := binary.LittleEndian.Uint64(linkedit[40:]), binary.LittleEndian.Uint64(linkedit[48:]); fileOff+fileSize != uint64(len(data)) {
+				t.Errorf("__LINKEDIT ends at %d, want %d", fileOff+fileSize, len(data))
+			}
+
+			if vmSize := binary.LittleEndian.Uint64(linkedit[32:]); vmSize != segmentPageSize {
+				t.Errorf("__LINKEDIT vmsize = 0x%x, want 0x%x", vmSize, segmentPageSize)
+			}
+		})
+	}
+}
+
+func TestStripCodeSignatureTrailingData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Ex")
+
+	original := testBinary(MH_EXECUTE, 0x100, bytes.Repeat([]byte{0xCC}, 0x200), 0x10)
+
+	err := os.WriteFile(path, original, 0755)
+	if err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+
+	_, err = StripCodeSignature(path)
+	if err == nil {
+		t.Fatalf("StripCodeSignature() succeeded, want error")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read binary: %v", err)
+	}
+
+	// The binary must be left untouched
+	if !bytes.Equal(data, original) {
+		t.Errorf("StripCodeSignature() modified the binary")
+	}
+}
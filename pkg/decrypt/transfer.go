@@ -0,0 +1,715 @@
+package decrypt
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Transfer defines the strategy used to pull the app bundle from the device.
+type Transfer string
+
+const (
+	// TransferSFTP pulls every file of the app bundle individually via SFTP. Files that have already been pulled are
+	// skipped, and interrupted transfers are resumed.
+	TransferSFTP Transfer = "sftp"
+
+	// TransferTar streams the app bundle as a single tar archive over an SSH session. This is considerably faster
+	// for bundles with many small files, but requires tar to be installed on the device. Interrupted transfers
+	// start from scratch.
+	TransferTar Transfer = "tar"
+
+	// TransferFrida reads the app bundle through a Frida script, streaming file contents as binary messages. This
+	// is slower than the other strategies, but works on devices that run frida-server without an SSH server.
+	TransferFrida Transfer = "frida"
+)
+
+// pullBundle pulls the app bundle to localPath using the dumper's transfer strategy. The size of the bundle is taken
+// from scan if the bundle has been scanned before (scan is nil otherwise).
+//
+// When pulling into a work directory kept from a previous run, files that are no longer part of the app bundle and
+// partial files of interrupted transfers are removed afterwards.
+func (d *Dumper) pullBundle(ctx context.Context, app *Application, localPath string, scan *ScanResult) error {
+	pulled := make(map[string]bool)
+
+	// Pull files
+	err := d.pullBundleFiles(ctx, app, localPath, scan, pulled)
+	if err != nil {
+		return err
+	}
+
+	// Remove files that haven't been pulled
+	err = pruneDir(localPath, pulled)
+	if err != nil {
+		return fmt.Errorf("remove stale files: %w", err)
+	}
+
+	return nil
+}
+
+// pullBundleFiles pulls the files of the app bundle to localPath, and records the local paths of all pulled files and
+// directories in pulled.
+func (d *Dumper) pullBundleFiles(ctx context.Context, app *Application, localPath string, scan *ScanResult, pulled map[string]bool) error {
+	transfer := d.opts.Transfer
+	progress := d.opts.Progress
+
+	// Frida doesn't need an SSH connection
+	if transfer == TransferFrida {
+		return d.pullDirFrida(ctx, app.Path, localPath, pulled)
+	}
+
+	if transfer != TransferSFTP && transfer != TransferTar {
+		return fmt.Errorf("unknown transfer strategy [%s]", transfer)
+	}
+
+	// Establish SSH and SFTP connections
+	err := d.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Close the connection on cancellation, which aborts all pending operations
+	stop := context.AfterFunc(ctx, func() { d.ssh.Close() })
+	defer stop()
+
+	// Determine size of the bundle (SFTP is optional for tar)
+	var totalBytes int64
+	var totalFiles int
+
+	if scan != nil {
+		totalBytes, totalFiles = scan.Size, scan.Files
+	} else if d.sftp != nil {
+		totalBytes, totalFiles = remoteDirSize(d.sftp, app.Path)
+	}
+
+	// Use tar if requested and available
+	if transfer == TransferTar {
+		if hasRemoteCommand(d.ssh, "tar") {
+			tp := startTransferProgress(progress, "Pulling app bundle", totalBytes, totalFiles)
+			defer progress.Stop()
+
+			return pullDirTar(ctx, d.ssh, app.Path, localPath, pulled, tp)
+		}
+
+		slog.Warn("The 'tar' command is not available on the device, falling back to SFTP")
+	}
+
+	if d.sftp == nil {
+		return fmt.Errorf("SFTP subsystem not available")
+	}
+
+	tp := startTransferProgress(progress, "Pulling app bundle", totalBytes, totalFiles)
+	defer progress.Stop()
+
+	return pullDir(ctx, d.sftp, app.Path, localPath, pulled, tp)
+}
+
+// pruneDir removes all files and directories below localPath that are not in pulled, which holds the local paths of
+// all pulled files and directories. Parent directories of pulled entries are kept.
+func pruneDir(localPath string, pulled map[string]bool) error {
+	// Keep pulled entries and their parent directories
+	keep := make(map[string]bool, len(pulled))
+
+	for path := range pulled {
+		for path != localPath && !keep[path] && filepath.Dir(path) != path {
+			keep[path] = true
+			path = filepath.Dir(path)
+		}
+	}
+
+	// Remove everything else
+	return filepath.WalkDir(localPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == localPath && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if path == localPath || keep[path] {
+			return nil
+		}
+
+		slog.Debug("Removing stale file", slog.String("path", path))
+
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
+
+const (
+	// defaultSSHUser is the user the dumper logs in as over SSH if SSHOptions.User is not set.
+	defaultSSHUser = "mobile"
+
+	// defaultSSHPassword is the password of the SSH user if SSHOptions.Password is not set.
+	defaultSSHPassword = "alpine"
+)
+
+// SSHOptions configures the SSH connection to the device.
+type SSHOptions struct {
+	User     string // User is the user to log in as ("mobile" if empty).
+	Password string // Password is the password of User, which is also passed to sudo ("alpine" if empty).
+}
+
+// credentials returns the user and password to log in with, falling back to the defaults.
+func (opts SSHOptions) credentials() (string, string) {
+	user, password := opts.User, opts.Password
+
+	if user == "" {
+		user = defaultSSHUser
+	}
+
+	if password == "" {
+		password = defaultSSHPassword
+	}
+
+	return user, password
+}
+
+// connect establishes the SSH connection of the dumper, and the SFTP connection if the subsystem is available. The
+// connections are only established once and then reused.
+func (d *Dumper) connect(ctx context.Context) error {
+	if d.ssh != nil {
+		return nil
+	}
+
+	// Establish SSH connection
+	user, password := d.opts.SSH.credentials()
+
+	sshClient, err := dialSSH(ctx, "localhost:2222", &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         30 * time.Second,
+	})
+
+	if err != nil {
+		return fmt.Errorf("establish SSH connect: %w", err)
+	}
+
+	d.ssh = sshClient
+
+	// Establish SFTP connection
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		slog.Debug("Failed to establish SFTP connection", slog.Any("error", err))
+		return nil
+	}
+
+	d.sftp = sftpClient
+
+	return nil
+}
+
+// remoteDirSize returns the total size and number of files below a remote directory. Errors are ignored, as the
+// result is only used for reporting progress.
+func remoteDirSize(sftpClient *sftp.Client, remotePath string) (int64, int) {
+	var size int64
+	var files int
+
+	walker := sftpClient.Walk(remotePath)
+
+	for walker.Step() {
+		if walker.Err() == nil && walker.Stat().Mode().IsRegular() {
+			size += walker.Stat().Size()
+			files++
+		}
+	}
+
+	return size, files
+}
+
+// dialSSH establishes an SSH connection to addr, giving up once the context is done.
+func dialSSH(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	// Establish TCP connection
+	dialer := net.Dialer{Timeout: config.Timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Abort handshake on cancellation
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// Perform SSH handshake
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if !stop() {
+		c.Close()
+		return nil, ctx.Err()
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// hasRemoteCommand checks whether a command is available on the remote device.
+func hasRemoteCommand(sshClient *ssh.Client, name string) bool {
+	// Open SSH session
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return false
+	}
+
+	defer session.Close()
+
+	// Look up command
+	return session.Run("command -v "+shellQuote(name)) == nil
+}
+
+// pullDir recursively pulls a directory from the remote SFTP server to the local filesystem, and records the local
+// paths of all pulled files and directories in pulled.
+func pullDir(ctx context.Context, sftpClient *sftp.Client, remotePath string, localPath string, pulled map[string]bool, tp *transferProgress) error {
+	// Read remote directory
+	entries, err := sftpClient.ReadDir(remotePath)
+	if err != nil {
+		return fmt.Errorf("read remote directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		// Entry paths
+		remotePathEntry := remotePath + "/" + entry.Name()
+		localPathEntry := filepath.Join(localPath, entry.Name())
+
+		if entry.IsDir() {
+			// Dive into directories recursively
+			if err := pullDir(ctx, sftpClient, remotePathEntry, localPathEntry, pulled, tp); err != nil {
+				return err
+			}
+
+			pulled[localPathEntry] = true
+		} else {
+			// Ensure local directory exists
+			err := os.MkdirAll(localPath, 0755)
+			if err != nil {
+				return fmt.Errorf("ensure local directory exists: %w", err)
+			}
+
+			// Pull remote file
+			if err := pullFile(ctx, sftpClient, remotePathEntry, localPathEntry, tp); err != nil {
+				return fmt.Errorf("pull file [%s]: %w", remotePathEntry, err)
+			}
+
+			pulled[localPathEntry] = true
+			tp.fileDone()
+		}
+	}
+
+	return nil
+}
+
+// pullFile pulls a single file from the remote SFTP server to the local filesystem.
+//
+// Files that have already been pulled completely (same size and modification time as the remote file) are skipped.
+// Data is first written to a partial file next to the local path, so an interrupted transfer can be resumed at the
+// offset where it stopped, as long as the remote file hasn't changed in the meantime.
+func pullFile(ctx context.Context, sftpClient *sftp.Client, remotePath string, localPath string, tp *transferProgress) error {
+	// Open remote file
+	remoteFile, err := sftpClient.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("open remote file: %w", err)
+	}
+
+	defer remoteFile.Close()
+
+	remoteInfo, err := remoteFile.Stat()
+	if err != nil {
+		return fmt.Errorf("read remote stats: %w", err)
+	}
+
+	// Skip if already pulled
+	localInfo, err := os.Stat(localPath)
+	if err == nil && localInfo.Size() == remoteInfo.Size() && localInfo.ModTime().Equal(remoteInfo.ModTime()) {
+		slog.Debug("Skipping unchanged file", slog.String("path", localPath))
+		tp.progress.Add(remoteInfo.Size())
+
+		return nil
+	}
+
+	// Open partial file, and resume if possible
+	partialPath := localPath + ".partial"
+
+	partialFile, offset, err := openPartialFile(partialPath, remoteInfo.Size(), remoteInfo.ModTime())
+	if err != nil {
+		return fmt.Errorf("open partial file: %w", err)
+	}
+
+	defer partialFile.Close()
+
+	if offset > 0 {
+		slog.Debug("Resuming partial file", slog.String("path", localPath), slog.Int64("offset", offset))
+
+		_, err = remoteFile.Seek(offset, io.SeekStart)
+		if err != nil {
+			return fmt.Errorf("seek remote file: %w", err)
+		}
+
+		tp.progress.Add(offset)
+	}
+
+	// Copy content
+	_, err = io.Copy(partialFile, io.TeeReader(&contextReader{ctx: ctx, r: remoteFile}, tp))
+	if err != nil {
+		return fmt.Errorf("copy content: %w", err)
+	}
+
+	err = partialFile.Close()
+	if err != nil {
+		return fmt.Errorf("close partial file: %w", err)
+	}
+
+	// Move partial file into place
+	err = os.Rename(partialPath, localPath)
+	if err != nil {
+		return fmt.Errorf("rename partial file: %w", err)
+	}
+
+	// Set file permissions and timestamps
+	if err := os.Chmod(localPath, remoteInfo.Mode()); err != nil {
+		slog.Warn("Failed to set file permissions", slog.String("path", localPath), slog.Any("error", err))
+	}
+
+	if err := os.Chtimes(localPath, remoteInfo.ModTime(), remoteInfo.ModTime()); err != nil {
+		slog.Warn("Failed to set file timestamps", slog.String("path", localPath), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// openPartialFile opens the partial file for a transfer and returns the offset at which to continue. An existing
+// partial file is only resumed if it's smaller than the remote file and was written after the remote file has been
+// last modified. Otherwise, it's truncated and the transfer starts from scratch.
+func openPartialFile(path string, remoteSize int64, remoteModTime time.Time) (*os.File, int64, error) {
+	// Check for existing partial file
+	info, err := os.Stat(path)
+	if err == nil && info.Size() < remoteSize && info.ModTime().After(remoteModTime) {
+		// Resume partial file
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return file, info.Size(), nil
+	}
+
+	// Start from scratch
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return file, 0, nil
+}
+
+// pullDirTar pulls a directory from the remote device to the local filesystem by streaming it as a tar archive, and
+// records the local paths of all pulled files and directories in pulled.
+func pullDirTar(ctx context.Context, sshClient *ssh.Client, remotePath string, localPath string, pulled map[string]bool, tp *transferProgress) error {
+	// Open SSH session
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return fmt.Errorf("open SSH session: %w", err)
+	}
+
+	defer session.Close()
+
+	// Start tar on the device (following symlinks, just like SFTP does)
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("get stdout of SSH session: %w", err)
+	}
+
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	err = session.Start("tar -chf - -C " + shellQuote(remotePath) + " .")
+	if err != nil {
+		return fmt.Errorf("start remote tar: %w", err)
+	}
+
+	// Extract archive
+	err = extractTar(&contextReader{ctx: ctx, r: stdout}, localPath, pulled, tp)
+	if err != nil {
+		return fmt.Errorf("extract archive: %w", err)
+	}
+
+	// Wait for tar to finish
+	err = session.Wait()
+	if err != nil {
+		return fmt.Errorf("remote tar [%s]: %w", strings.TrimSpace(stderr.String()), err)
+	}
+
+	return nil
+}
+
+// extractTar extracts a tar stream into the local directory, and records the local paths of all extracted files and
+// directories in pulled.
+func extractTar(r io.Reader, localPath string, pulled map[string]bool, tp *transferProgress) error {
+	tr := tar.NewReader(r)
+
+	for {
+		// Read next header
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("read header: %w", err)
+		}
+
+		// Make sure the entry does not escape the local directory
+		name := filepath.FromSlash(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "" || name == "." {
+			continue
+		}
+
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path in archive [%s]", hdr.Name)
+		}
+
+		path := filepath.Join(localPath, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// Create directory
+			err := os.MkdirAll(path, 0755)
+			if err != nil {
+				return fmt.Errorf("create local directory: %w", err)
+			}
+
+			pulled[path] = true
+
+		case tar.TypeReg:
+			// Create file
+			err := extractTarFile(tr, hdr, path, tp)
+			if err != nil {
+				return fmt.Errorf("extract file [%s]: %w", hdr.Name, err)
+			}
+
+			pulled[path] = true
+			tp.fileDone()
+
+		default:
+			slog.Warn("Skipping unsupported archive entry", slog.String("path", hdr.Name))
+		}
+	}
+}
+
+// extractTarFile extracts a single regular file from a tar stream.
+func extractTarFile(tr *tar.Reader, hdr *tar.Header, path string, tp *transferProgress) error {
+	// Ensure local directory exists
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("ensure local directory exists: %w", err)
+	}
+
+	// Create local file
+	localFile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create local file: %w", err)
+	}
+
+	defer localFile.Close()
+
+	// Copy content
+	_, err = io.Copy(localFile, io.TeeReader(tr, tp))
+	if err != nil {
+		return fmt.Errorf("copy content: %w", err)
+	}
+
+	// Set file permissions and timestamps
+	if err := os.Chmod(path, hdr.FileInfo().Mode()); err != nil {
+		slog.Warn("Failed to set file permissions", slog.String("path", path), slog.Any("error", err))
+	}
+
+	if err := os.Chtimes(path, hdr.ModTime, hdr.ModTime); err != nil {
+		slog.Warn("Failed to set file timestamps", slog.String("path", path), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// fridaChunkSize is the maximum size of a file chunk streamed by the filesystem script.
+const fridaChunkSize = 1 << 20
+
+// remoteEntry represents a file or directory listed by the filesystem script.
+type remoteEntry struct {
+	Path  string  `mapstructure:"path"`  // Path relative to the listed directory
+	Type  string  `mapstructure:"type"`  // Type can be "file", "directory" or "other"
+	Size  int64   `mapstructure:"size"`  // Size of the file in bytes
+	Mode  uint32  `mapstructure:"mode"`  // POSIX permissions
+	MTime float64 `mapstructure:"mtime"` // Modification time in seconds since the epoch
+}
+
+// pullDirFrida recursively pulls a directory from the device to the local filesystem via a Frida script, and records
+// the local paths of all pulled files and directories in pulled.
+func (d *Dumper) pullDirFrida(ctx context.Context, remotePath string, localPath string, pulled map[string]bool) error {
+	progress := d.opts.Progress
+
+	// Load script into runningboardd process
+	script, err := d.script(ctx, "filesystem.js")
+	if err != nil {
+		return fmt.Errorf("load script into process: %w", err)
+	}
+
+	// List remote directory
+	entries, err := CallAs[[]remoteEntry](ctx, script, "list", remotePath)
+	if err != nil {
+		return fmt.Errorf("list remote directory: %w", err)
+	}
+
+	var totalBytes int64
+	var totalFiles int
+
+	for _, entry := range entries {
+		if entry.Type == "file" {
+			totalBytes += entry.Size
+			totalFiles++
+		}
+	}
+
+	tp := startTransferProgress(progress, "Pulling app bundle", totalBytes, totalFiles)
+	defer progress.Stop()
+
+	for _, entry := range entries {
+		// Entry paths
+		remotePathEntry := remotePath + "/" + entry.Path
+		localPathEntry := filepath.Join(localPath, filepath.FromSlash(entry.Path))
+
+		switch entry.Type {
+		case "directory":
+			// Create directory
+			err := os.MkdirAll(localPathEntry, 0755)
+			if err != nil {
+				return fmt.Errorf("create local directory: %w", err)
+			}
+
+			pulled[localPathEntry] = true
+
+		case "file":
+			// Ensure local directory exists
+			err := os.MkdirAll(filepath.Dir(localPathEntry), 0755)
+			if err != nil {
+				return fmt.Errorf("ensure local directory exists: %w", err)
+			}
+
+			// Pull remote file
+			if err := pullFileFrida(ctx, script, entry, remotePathEntry, localPathEntry, tp); err != nil {
+				return fmt.Errorf("pull file [%s]: %w", remotePathEntry, err)
+			}
+
+			pulled[localPathEntry] = true
+			tp.fileDone()
+
+		default:
+			slog.Warn("Skipping unsupported remote entry", slog.String("path", remotePathEntry))
+		}
+	}
+
+	return nil
+}
+
+// pullFileFrida pulls a single file from the device to the local filesystem via a Frida script. Just like pullFile,
+// unchanged files are skipped and partial files are resumed.
+func pullFileFrida(ctx context.Context, script *Script, entry remoteEntry, remotePath string, localPath string, tp *transferProgress) error {
+	modTime := time.UnixMilli(int64(entry.MTime * 1000))
+
+	// Skip if already pulled
+	localInfo, err := os.Stat(localPath)
+	if err == nil && localInfo.Size() == entry.Size && localInfo.ModTime().Equal(modTime) {
+		slog.Debug("Skipping unchanged file", slog.String("path", localPath))
+		tp.progress.Add(entry.Size)
+
+		return nil
+	}
+
+	// Open partial file, and resume if possible
+	partialPath := localPath + ".partial"
+
+	partialFile, offset, err := openPartialFile(partialPath, entry.Size, modTime)
+	if err != nil {
+		return fmt.Errorf("open partial file: %w", err)
+	}
+
+	defer partialFile.Close()
+
+	tp.progress.Add(offset)
+
+	// Stream content
+	var writeErr error
+
+	unsubscribe := script.Subscribe("chunk", func(_ map[string]any, data []byte) {
+		if writeErr == nil {
+			_, writeErr = partialFile.Write(data)
+			tp.progress.Add(int64(len(data)))
+		}
+	})
+
+	defer unsubscribe()
+
+	end, err := CallAs[int64](ctx, script, "read", remotePath, offset, fridaChunkSize)
+	if err != nil {
+		return fmt.Errorf("read remote file: %w", err)
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("write partial file: %w", writeErr)
+	}
+
+	if end != entry.Size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d bytes", entry.Size, end)
+	}
+
+	err = partialFile.Close()
+	if err != nil {
+		return fmt.Errorf("close partial file: %w", err)
+	}
+
+	// Move partial file into place
+	err = os.Rename(partialPath, localPath)
+	if err != nil {
+		return fmt.Errorf("rename partial file: %w", err)
+	}
+
+	// Set file permissions and timestamps
+	if err := os.Chmod(localPath, os.FileMode(entry.Mode)); err != nil {
+		slog.Warn("Failed to set file permissions", slog.String("path", localPath), slog.Any("error", err))
+	}
+
+	if err := os.Chtimes(localPath, modTime, modTime); err != nil {
+		slog.Warn("Failed to set file timestamps", slog.String("path", localPath), slog.Any("error", err))
+	}
+
+	return nil
+}
+
+// shellQuote quotes a string for safe use in a POSIX shell command line.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,319 @@
+package decrypt
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTarEntry is an entry of a tar archive in a test.
+type testTarEntry struct {
+	Name     string // Name is the path of the entry.
+	Type     byte   // Type is the type flag of the entry.
+	Body     string // Body is the content of regular files.
+	Linkname string // Linkname is the target of links.
+}
+
+// testTar builds a tar archive with the given entries.
+func testTar(t *testing.T, entries ...testTarEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.Name,
+			Typeflag: e.Type,
+			Linkname: e.Linkname,
+			Mode:     0644,
+			ModTime:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+
+		if e.Type == tar.TypeReg {
+			hdr.Size = int64(len(e.Body))
+		}
+
+		if e.Type == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+
+		err := tw.WriteHeader(hdr)
+		if err != nil {
+			t.Fatalf("write header [%s]: %v", e.Name, err)
+		}
+
+		_, err = tw.Write([]byte(e.Body))
+		if err != nil {
+			t.Fatalf("write body [%s]: %v", e.Name, err)
+		}
+	}
+
+	err := tw.Close()
+	if err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// testListFiles returns the slash-separated paths of all files, directories and symlinks below dir.
+func testListFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var paths []string
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.Type()&os.ModeSymlink != 0:
+			rel += "@"
+		case d.IsDir():
+			rel += "/"
+		}
+
+		paths = append(paths, filepath.ToSlash(rel))
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("walk directory: %v", err)
+	}
+
+	return paths
+}
+
+func TestExtractTar(t *testing.T) {
+	archive := testTar(t,
+		testTarEntry{Name: "./", Type: tar.TypeDir},
+		testTarEntry{Name: "./Ex", Type: tar.TypeReg, Body: "executable"},
+		testTarEntry{Name: "./Frameworks/", Type: tar.TypeDir},
+		testTarEntry{Name: "./Frameworks/A.framework/A", Type: tar.TypeReg, Body: strings.Repeat("framework", 1000)})
+
+	tests := []struct {
+		name    string   // name is the name of the test.
+		data    []byte   // data is the tar stream.
+		want    []string // want are the paths expected in the work directory (directories end with "/").
+		wantErr bool     // wantErr is true if extracting is expected to fail.
+	}{
+		{
+			name: "files and directories",
+			data: archive,
+			want: []string{"bundle/", "bundle/Ex", "bundle/Frameworks/", "bundle/Frameworks/A.framework/", "bundle/Frameworks/A.framework/A"},
+		},
+		{
+			name:    "parent directory",
+			data:    testTar(t, testTarEntry{Name: "../evil", Type: tar.TypeReg, Body: "evil"}),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "parent directory within path",
+			data:    testTar(t, testTarEntry{Name: "./Frameworks/../../evil", Type: tar.TypeReg, Body: "evil"}),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "absolute path",
+			data:    testTar(t, testTarEntry{Name: "/tmp/evil", Type: tar.TypeReg, Body: "evil"}),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "absolute directory",
+			data:    testTar(t, testTarEntry{Name: "/tmp/evil/", Type: tar.TypeDir}),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "symlink escaping",
+			data: testTar(t,
+				testTarEntry{Name: "./link", Type: tar.TypeSymlink, Linkname: "../.."},
+				testTarEntry{Name: "./link/evil", Type: tar.TypeReg, Body: "evil"}),
+			want: []string{"bundle/", "bundle/link/", "bundle/link/evil"},
+		},
+		{
+			name: "hard link",
+			data: testTar(t,
+				testTarEntry{Name: "./Ex", Type: tar.TypeReg, Body: "executable"},
+				testTarEntry{Name: "./evil", Type: tar.TypeLink, Linkname: "/etc/passwd"}),
+			want: []string{"bundle/", "bundle/Ex"},
+		},
+		{
+			name:    "truncated stream",
+			data:    archive[:len(archive)-4096],
+			wantErr: true,
+		},
+		{
+			name:    "truncated header",
+			data:    archive[:700],
+			wantErr: true,
+		},
+		{
+			name:    "not an archive",
+			data:    bytes.Repeat([]byte("garbage!"), 128),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			localPath := filepath.Join(dir, "bundle")
+
+			err := extractTar(bytes.NewReader(tt.data), localPath, make(map[string]bool), startTransferProgress(nopProgress{}, "", 0, 0))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("extractTar() succeeded, want error")
+				}
+
+				// Nothing may be written outside of the local directory
+				for _, path := range testListFiles(t, dir) {
+					if !strings.HasPrefix(path, "bundle/") {
+						t.Errorf("extractTar() wrote [%s] outside of the local directory", path)
+					}
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("extractTar() = %v", err)
+			}
+
+			if got := testListFiles(t, dir); !slices.Equal(got, tt.want) {
+				t.Errorf("extractTar() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractTarFile(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	archive := testTar(t, testTarEntry{Name: "./Frameworks/A.framework/A", Type: tar.TypeReg, Body: "framework"})
+
+	localPath := t.TempDir()
+
+	err := extractTar(bytes.NewReader(archive), localPath, make(map[string]bool), startTransferProgress(nopProgress{}, "", 0, 0))
+	if err != nil {
+		t.Fatalf("extractTar() = %v", err)
+	}
+
+	// Content, permissions and timestamps are taken from the archive
+	path := filepath.Join(localPath, "Frameworks", "A.framework", "A")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+
+	if string(data) != "framework" {
+		t.Errorf("content = %q, want %q", data, "framework")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat file: %v", err)
+	}
+
+	if info.Mode().Perm() != 0644 || !info.ModTime().Equal(modTime) {
+		t.Errorf("mode = %v, modification time = %v, want %v, %v", info.Mode().Perm(), info.ModTime(), os.FileMode(0644), modTime)
+	}
+}
+
+func TestPruneDir(t *testing.T) {
+	tests := []struct {
+		name   string   // name is the name of the test.
+		files  []string // files are the paths in the work directory before pruning (directories end with "/").
+		pulled []string // pulled are the paths that have been pulled.
+		want   []string // want are the paths expected in the work directory after pruning.
+	}{
+		{
+			name:   "nothing stale",
+			files:  []string{"Ex", "Frameworks/", "Frameworks/A"},
+			pulled: []string{"Ex", "Frameworks", "Frameworks/A"},
+			want:   []string{"Ex", "Frameworks/", "Frameworks/A"},
+		},
+		{
+			name:   "stale file",
+			files:  []string{"Ex", "Old.png"},
+			pulled: []string{"Ex"},
+			want:   []string{"Ex"},
+		},
+		{
+			name:   "stale directory",
+			files:  []string{"Ex", "Old.bundle/", "Old.bundle/Info.plist"},
+			pulled: []string{"Ex"},
+			want:   []string{"Ex"},
+		},
+		{
+			name:   "partial file",
+			files:  []string{"Ex", "Frameworks/", "Frameworks/A", "Frameworks/B.partial"},
+			pulled: []string{"Ex", "Frameworks/A"},
+			want:   []string{"Ex", "Frameworks/", "Frameworks/A"},
+		},
+		{
+			name:   "nothing pulled",
+			files:  []string{"Ex", "Frameworks/", "Frameworks/A"},
+			pulled: nil,
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localPath := t.TempDir()
+
+			for _, file := range tt.files {
+				path := filepath.Join(localPath, filepath.FromSlash(file))
+
+				var err error
+				if strings.HasSuffix(file, "/") {
+					err = os.MkdirAll(path, 0755)
+				} else {
+					err = os.WriteFile(path, []byte(file), 0644)
+				}
+
+				if err != nil {
+					t.Fatalf("create [%s]: %v", file, err)
+				}
+			}
+
+			pulled := make(map[string]bool)
+			for _, file := range tt.pulled {
+				pulled[filepath.Join(localPath, filepath.FromSlash(file))] = true
+			}
+
+			err := pruneDir(localPath, pulled)
+			if err != nil {
+				t.Fatalf("pruneDir() = %v", err)
+			}
+
+			if got := testListFiles(t, localPath); !slices.Equal(got, tt.want) {
+				t.Errorf("pruneDir() kept %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneDirMissing(t *testing.T) {
+	err := pruneDir(filepath.Join(t.TempDir(), "bundle"), nil)
+	if err != nil {
+		t.Errorf("pruneDir() = %v, want nil", err)
+	}
+}